@@ -0,0 +1,243 @@
+package main
+
+import (
+	"strings"
+	"time"
+
+	"github.com/iancoleman/strcase"
+	"github.com/koron/go-dproxy"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// RTPAggregateConfig configures the per-kind aggregates of the inbound and
+// outbound RTP stream counters.
+type RTPAggregateConfig struct {
+	// ByCodec also splits the aggregates by codec MIME type.
+	ByCodec bool `yaml:"by_codec,omitempty"`
+	// DropPerStream stops exporting the per-stream inbound-rtp and
+	// outbound-rtp series.
+	DropPerStream bool `yaml:"drop_per_stream,omitempty"`
+}
+
+// rtpAggregateRetention is how long the last counters of a stream that is
+// gone are remembered, in case it comes back.
+const rtpAggregateRetention = 10 * time.Minute
+
+// inboundRTPAggregateHelp and outboundRTPAggregateHelp are the help of the
+// aggregated counters, by stats field. qpSum is not aggregated, as QP ranges
+// differ between codecs.
+var (
+	inboundRTPAggregateHelp = map[string]string{
+		"bytesReceived":        "Total number of bytes received on the inbound RTP streams of the kind, including the streams that ended.",
+		"headerBytesReceived":  "Total number of RTP header and padding bytes received on the inbound RTP streams of the kind, including the streams that ended.",
+		"packetsReceived":      "Total number of RTP packets received on the inbound RTP streams of the kind, including the streams that ended.",
+		"framesReceived":       "Total number of complete frames received on the inbound RTP streams of the kind, including the streams that ended.",
+		"firCount":             "Total number of Full Intra Request (FIR) packets sent for the inbound RTP streams of the kind, including the streams that ended.",
+		"pliCount":             "Total number of Picture Loss Indication (PLI) packets sent for the inbound RTP streams of the kind, including the streams that ended.",
+		"sliCount":             "Total number of Slice Loss Indication (SLI) packets sent for the inbound RTP streams of the kind, including the streams that ended.",
+		"nackCount":            "Total number of Negative ACKnowledgement (NACK) packets sent for the inbound RTP streams of the kind, including the streams that ended.",
+		"framesDecoded":        "Total number of frames correctly decoded from the inbound RTP streams of the kind, including the streams that ended.",
+		"keyFramesDecoded":     "Total number of key frames successfully decoded from the inbound RTP streams of the kind, including the streams that ended.",
+		"totalDecodeTime":      "Total number of seconds spent decoding the frames of the inbound RTP streams of the kind, including the streams that ended.",
+		"totalSamplesReceived": "Total number of samples received on the inbound RTP streams of the kind, including the streams that ended.",
+	}
+	outboundRTPAggregateHelp = map[string]string{
+		"bytesSent":                          "Total number of bytes sent on the outbound RTP streams of the kind, including the streams that ended.",
+		"headerBytesSent":                    "Total number of RTP header and padding bytes sent on the outbound RTP streams of the kind, including the streams that ended.",
+		"retransmittedBytesSent":             "Total number of bytes retransmitted on the outbound RTP streams of the kind, including the streams that ended.",
+		"packetsSent":                        "Total number of RTP packets sent on the outbound RTP streams of the kind, including the streams that ended.",
+		"retransmittedPacketsSent":           "Total number of RTP packets retransmitted on the outbound RTP streams of the kind, including the streams that ended.",
+		"framesSent":                         "Total number of frames sent on the outbound RTP streams of the kind, including the streams that ended.",
+		"firCount":                           "Total number of Full Intra Request (FIR) packets received for the outbound RTP streams of the kind, including the streams that ended.",
+		"pliCount":                           "Total number of Picture Loss Indication (PLI) packets received for the outbound RTP streams of the kind, including the streams that ended.",
+		"sliCount":                           "Total number of Slice Loss Indication (SLI) packets received for the outbound RTP streams of the kind, including the streams that ended.",
+		"nackCount":                          "Total number of Negative ACKnowledgement (NACK) packets received for the outbound RTP streams of the kind, including the streams that ended.",
+		"framesEncoded":                      "Total number of frames successfully encoded for the outbound RTP streams of the kind, including the streams that ended.",
+		"keyFramesEncoded":                   "Total number of key frames successfully encoded for the outbound RTP streams of the kind, including the streams that ended.",
+		"totalEncodeTime":                    "Total number of seconds spent encoding the frames of the outbound RTP streams of the kind, including the streams that ended.",
+		"totalPacketSendDelay":               "Total number of seconds that packets of the outbound RTP streams of the kind, including the streams that ended, spent buffered locally before being transmitted.",
+		"totalSamplesSent":                   "Total number of samples sent on the outbound RTP streams of the kind, including the streams that ended.",
+		"qualityLimitationResolutionChanges": "Number of times the resolution of the outbound RTP streams of the kind, including the streams that ended, changed because of quality limitations.",
+	}
+)
+
+// rtpAggregates holds the descriptors of the aggregates of one RTP
+// stats type, and the state keeping them monotonic.
+type rtpAggregates struct {
+	metrics metrics
+	streams metricInfo
+
+	// aggregates and rtpStreams are keyed by the joined aggregate labels and
+	// the stream id.
+	aggregates map[string]*rtpAggregate
+	rtpStreams map[string]*rtpStream
+}
+
+// newRTPAggregates returns the aggregates of the counters of table with help,
+// named <category>_kind_<metric>, leaving out those denied by filter. streams
+// is empty if it is denied.
+func newRTPAggregates(category string, table metrics, help map[string]string, labelNames []string, filter *MetricFilter) *rtpAggregates {
+	d := &rtpAggregates{
+		metrics:    metrics{},
+		streams:    newMetric(category, "kind_streams", "Number of streams summed into the aggregates.", prometheus.GaugeValue, labelNames, nil),
+		aggregates: map[string]*rtpAggregate{},
+		rtpStreams: map[string]*rtpStream{},
+	}
+	for key, h := range help {
+		m := table[key]
+		if m = newMetric(category, "kind_"+m.Name, h, m.Type, labelNames, nil); filter.metricAllowed(m.FQName) {
+			d.metrics[key] = m
+		}
+	}
+	if !filter.metricAllowed(d.streams.FQName) {
+		d.streams = metricInfo{}
+	}
+	return d
+}
+
+func (d *rtpAggregates) describe(ch chan<- *prometheus.Desc) {
+	for _, m := range d.metrics {
+		ch <- m.Desc
+	}
+	if d.streams.Desc != nil {
+		ch <- d.streams.Desc
+	}
+}
+
+// rtpAggregator sums the RTP stream counters per kind and optionally codec.
+type rtpAggregator struct {
+	config   RTPAggregateConfig
+	inbound  *rtpAggregates
+	outbound *rtpAggregates
+}
+
+func newRTPAggregator(config RTPAggregateConfig, filter *MetricFilter) *rtpAggregator {
+	labelNames := []string{"kind"}
+	if config.ByCodec {
+		labelNames = append(labelNames, "codec")
+	}
+	return &rtpAggregator{
+		config:   config,
+		inbound:  newRTPAggregates("inbound_rtp", inboundRTPMetrics, inboundRTPAggregateHelp, labelNames, filter),
+		outbound: newRTPAggregates("outbound_rtp", outboundRTPMetrics, outboundRTPAggregateHelp, labelNames, filter),
+	}
+}
+
+// rtpAggregate holds the counters of the streams with the same labels.
+// The aggregate of a counter is its base plus the sum over the live streams,
+// where the base holds the last values of the streams that ended or reset,
+// so that the aggregate never decreases.
+type rtpAggregate struct {
+	labels []string
+	base   map[string]float64
+}
+
+// rtpStream is the last state of an aggregated stream.
+type rtpStream struct {
+	key      string
+	values   map[string]float64
+	lastSeen time.Time
+	// ended is set once the stream is gone from the stats. Its last values
+	// are then part of the base of its aggregate.
+	ended bool
+}
+
+func (a *rtpAggregator) export(t string, d *rtpAggregates, stats []interface{}, index statsIndex, now time.Time, ch chan<- prometheus.Metric) {
+	seen := map[string]bool{}
+	for _, s := range stats {
+		m := dproxy.New(s)
+		if st, _ := m.M("type").String(); st != t {
+			continue
+		}
+		id, _ := m.M("id").String()
+		labels := []string{labelValue(m, "kind")}
+		if a.config.ByCodec {
+			codecID, _ := m.M("codecId").String()
+			mimeType, _ := index[codecID]["mimeType"].(string)
+			labels = append(labels, mimeType)
+		}
+		key := strings.Join(labels, "\xff")
+		agg, ok := d.aggregates[key]
+		if !ok {
+			agg = &rtpAggregate{labels: labels, base: map[string]float64{}}
+			d.aggregates[key] = agg
+		}
+		values := make(map[string]float64, len(d.metrics))
+		for field := range d.metrics {
+			values[field], _ = m.M(strcase.ToLowerCamel(field)).Float64()
+		}
+
+		stream, ok := d.rtpStreams[id]
+		if ok && stream.key != key {
+			// The stream moved to another aggregate, e.g. on a codec
+			// change, so it counts as a new stream there.
+			if !stream.ended {
+				d.end(stream)
+			}
+			ok = false
+		}
+		if ok && stream.ended {
+			// The stream is back, so its last values are no longer part of
+			// the base.
+			for field, v := range stream.values {
+				agg.base[field] -= v
+			}
+			stream.ended = false
+		}
+		if ok {
+			for field, v := range values {
+				if last := stream.values[field]; v < last {
+					// The counter was reset.
+					agg.base[field] += last
+				}
+			}
+		} else {
+			stream = &rtpStream{key: key}
+			d.rtpStreams[id] = stream
+		}
+		stream.values = values
+		stream.lastSeen = now
+		seen[id] = true
+	}
+
+	live := map[string]int{}
+	for id, stream := range d.rtpStreams {
+		switch {
+		case seen[id]:
+			live[stream.key]++
+		case !stream.ended:
+			d.end(stream)
+		case now.Sub(stream.lastSeen) > rtpAggregateRetention:
+			delete(d.rtpStreams, id)
+		}
+	}
+
+	for key, agg := range d.aggregates {
+		totals := make(map[string]float64, len(agg.base))
+		for field, v := range agg.base {
+			totals[field] = v
+		}
+		for id := range seen {
+			if stream := d.rtpStreams[id]; stream.key == key {
+				for field, v := range stream.values {
+					totals[field] += v
+				}
+			}
+		}
+		if d.streams.Desc != nil {
+			ch <- prometheus.MustNewConstMetric(d.streams.Desc, prometheus.GaugeValue, float64(live[key]), agg.labels...)
+		}
+		for field, metric := range d.metrics {
+			ch <- prometheus.MustNewConstMetric(metric.Desc, metric.Type, totals[field], agg.labels...)
+		}
+	}
+}
+
+// end adds the last values of stream to the base of its aggregate.
+func (d *rtpAggregates) end(stream *rtpStream) {
+	agg := d.aggregates[stream.key]
+	for field, v := range stream.values {
+		agg.base[field] += v
+	}
+	stream.ended = true
+}
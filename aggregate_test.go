@@ -0,0 +1,134 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/common/model"
+)
+
+func TestRTPAggregate(t *testing.T) {
+	resp := `{
+		"version": "WebRTC Native Client Momo 2020.11 (db9d97e)",
+		"libwebrtc": "Shiguredo-Build M88.4324@{#2} (88.4324.2.0 54bd8488)",
+		"environment": "[aarch64] Ubuntu 18.04.5 LTS (nvidia-l4t-core 32.4.4-20201016123640)",
+		"stats": [
+			{"id": "RTCCodec_video_Inbound_96", "mimeType": "video/VP8", "type": "codec"},
+			{"id": "RTCCodec_video_Inbound_102", "mimeType": "video/H264", "type": "codec"},
+			{"id": "RTCCodec_audio_Inbound_111", "mimeType": "audio/opus", "type": "codec"},
+			{
+				"bytesReceived": 1000,
+				"codecId": "RTCCodec_video_Inbound_96",
+				"frameWidth": 1280,
+				"framesDecoded": 300,
+				"id": "RTCInboundRTPVideoStream_1",
+				"kind": "video",
+				"packetsReceived": 10,
+				"type": "inbound-rtp"
+			},
+			{
+				"bytesReceived": 2000,
+				"codecId": "RTCCodec_video_Inbound_96",
+				"frameWidth": 640,
+				"framesDecoded": 200,
+				"id": "RTCInboundRTPVideoStream_2",
+				"kind": "video",
+				"packetsReceived": 20,
+				"type": "inbound-rtp"
+			},
+			{
+				"bytesReceived": 4000,
+				"codecId": "RTCCodec_video_Inbound_102",
+				"framesDecoded": 100,
+				"id": "RTCInboundRTPVideoStream_3",
+				"kind": "video",
+				"packetsReceived": 40,
+				"type": "inbound-rtp"
+			},
+			{
+				"bytesReceived": 500,
+				"codecId": "RTCCodec_audio_Inbound_111",
+				"id": "RTCInboundRTPAudioStream_4",
+				"kind": "audio",
+				"packetsReceived": 50,
+				"totalSamplesReceived": 48000,
+				"type": "inbound-rtp"
+			}
+		]
+	}`
+	h := newMomo([]byte(resp))
+	defer h.Close()
+	e, err := NewExporter(TargetConfig{
+		URI:     h.URL,
+		Timeout: model.Duration(5 * time.Second),
+		ExportConfig: ExportConfig{
+			StatsTypes:   []string{"inbound-rtp"},
+			RTPAggregate: &RTPAggregateConfig{ByCodec: true, DropPerStream: true},
+		},
+	}, log.NewNopLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	e.now = func() time.Time { return testTime }
+	expectMetrics(t, e, "rtp_aggregate")
+}
+
+func TestRTPAggregateMonotonic(t *testing.T) {
+	h := newMomo(nil)
+	defer h.Close()
+	e, err := NewExporter(TargetConfig{
+		URI:     h.URL,
+		Timeout: model.Duration(5 * time.Second),
+		ExportConfig: ExportConfig{
+			StatsTypes:   []string{"inbound-rtp"},
+			RTPAggregate: &RTPAggregateConfig{ByCodec: true, DropPerStream: true},
+		},
+	}, log.NewNopLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := testTime
+	e.now = func() time.Time { return now }
+
+	stream := func(id, codec string, bytes int) string {
+		return fmt.Sprintf(`{"bytesReceived": %d, "codecId": %q, "id": %q, "kind": "video", "type": "inbound-rtp"}`, bytes, codec, id)
+	}
+	codecs := `{"id": "vp8", "mimeType": "video/VP8", "type": "codec"}, {"id": "h264", "mimeType": "video/H264", "type": "codec"}`
+	for i, step := range []struct {
+		streams []string
+		after   time.Duration
+		vp8     float64
+		h264    float64
+	}{
+		{streams: []string{stream("1", "vp8", 100), stream("2", "vp8", 200)}, vp8: 300},
+		// Stream 2 ended; its bytes stay in the total.
+		{streams: []string{stream("1", "vp8", 150)}, vp8: 350},
+		// Stream 2 is back and is not counted twice.
+		{streams: []string{stream("1", "vp8", 150), stream("2", "vp8", 250)}, vp8: 400},
+		// Stream 1 was reset.
+		{streams: []string{stream("1", "vp8", 10), stream("2", "vp8", 250)}, vp8: 410},
+		// Stream 2 switched to H.264.
+		{streams: []string{stream("1", "vp8", 10), stream("2", "h264", 300)}, vp8: 410, h264: 300},
+		// Stream 2 is gone for good, and its id is reused by a new stream.
+		{streams: []string{stream("1", "vp8", 10)}, vp8: 410, h264: 300},
+		{streams: []string{stream("1", "vp8", 10), stream("2", "h264", 5)}, after: rtpAggregateRetention + time.Minute, vp8: 410, h264: 305},
+	} {
+		now = now.Add(step.after)
+		h.response = []byte(fmt.Sprintf(`{"stats": [%s, %s]}`, codecs, strings.Join(step.streams, ", ")))
+		expected := fmt.Sprintf(`
+# HELP momo_inbound_rtp_kind_bytes_received_total Total number of bytes received on the inbound RTP streams of the kind, including the streams that ended.
+# TYPE momo_inbound_rtp_kind_bytes_received_total counter
+momo_inbound_rtp_kind_bytes_received_total{codec="video/VP8",kind="video"} %v
+`, step.vp8)
+		if step.h264 != 0 {
+			expected += fmt.Sprintf("momo_inbound_rtp_kind_bytes_received_total{codec=\"video/H264\",kind=\"video\"} %v\n", step.h264)
+		}
+		if err := testutil.CollectAndCompare(e, strings.NewReader(expected), "momo_inbound_rtp_kind_bytes_received_total"); err != nil {
+			t.Errorf("step %d: %v", i, err)
+		}
+	}
+}
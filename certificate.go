@@ -0,0 +1,85 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/go-kit/kit/log/level"
+	"github.com/koron/go-dproxy"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Roles of a certificate, from the transport that references it.
+const (
+	certificateRoleLocal  = "local"
+	certificateRoleRemote = "remote"
+)
+
+// https://www.w3.org/TR/webrtc-stats/#dom-rtccertificatestats
+var certificateInfo = newDesc("certificate", "info",
+	"DTLS certificate used by a transport. role is local or remote, or empty if no transport references the certificate.",
+	[]string{"id", "fingerprint_algorithm", "fingerprint", "role"})
+
+func newRemoteCertificateChanges() prometheus.Counter {
+	return prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "certificate_remote_fingerprint_changes_total",
+		Help:      "Number of times the remote certificate fingerprint of a transport changed within a DTLS session.",
+	})
+}
+
+// exportCertificateMetrics exports the certificate stats, with their role
+// taken from the transports referencing them, and counts remote fingerprint
+// changes of each transport between scrapes.
+func (e *Exporter) exportCertificateMetrics(stats []interface{}, index statsIndex, ch chan<- prometheus.Metric) {
+	roles := map[string]string{}
+	remoteFingerprints := map[string]string{}
+	for _, s := range stats {
+		p := dproxy.New(s)
+		if t, _ := p.M("type").String(); t != "transport" {
+			continue
+		}
+		id, _ := p.M("id").String()
+		local, _ := p.M("localCertificateId").String()
+		if local != "" {
+			roles[local] = certificateRoleLocal
+		}
+		remote, _ := p.M("remoteCertificateId").String()
+		if remote == "" {
+			continue
+		}
+		roles[remote] = certificateRoleRemote
+		// libwebrtc reuses transport ids across peer connections, and each
+		// peer connection has its own local certificate, so the session is
+		// the transport id and the local fingerprint. A session is only
+		// followed while DTLS is connected.
+		if state, _ := p.M("dtlsState").String(); state != "connected" {
+			continue
+		}
+		localFingerprint, _ := index[local]["fingerprint"].(string)
+		if fingerprint, ok := index[remote]["fingerprint"].(string); ok {
+			remoteFingerprints[id+"\xff"+localFingerprint] = fingerprint
+		}
+	}
+
+	for _, s := range stats {
+		p := dproxy.New(s)
+		if t, _ := p.M("type").String(); t != "certificate" {
+			continue
+		}
+		id, _ := p.M("id").String()
+		algorithm, _ := p.M("fingerprintAlgorithm").String()
+		fingerprint, _ := p.M("fingerprint").String()
+		e.sendConst(ch, certificateInfo, prometheus.GaugeValue, 1, id, algorithm, fingerprint, roles[id])
+	}
+
+	for session, fingerprint := range remoteFingerprints {
+		if last, ok := e.remoteFingerprints[session]; ok && last != fingerprint {
+			id := strings.SplitN(session, "\xff", 2)[0]
+			level.Warn(e.logger).Log("msg", "Remote certificate fingerprint changed", "transport", id, "old", last, "new", fingerprint)
+			e.remoteCertificateChanges.Inc()
+		}
+	}
+	// Sessions that are gone or no longer connected have ended, so a later
+	// session with the same key is compared afresh.
+	e.remoteFingerprints = remoteFingerprints
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func certificateResponse(transports string, localFingerprint, remoteFingerprint string) string {
+	return fmt.Sprintf(`{
+		"version": "WebRTC Native Client Momo 2020.11 (db9d97e)",
+		"libwebrtc": "Shiguredo-Build M88.4324@{#2} (88.4324.2.0 54bd8488)",
+		"environment": "[aarch64] Ubuntu 18.04.5 LTS (nvidia-l4t-core 32.4.4-20201016123640)",
+		"stats": [
+			%s
+			{
+				"base64Certificate": "MIIBFjCBvaADAgECAgkA...",
+				"fingerprint": %q,
+				"fingerprintAlgorithm": "sha-256",
+				"id": "RTCCertificate_local",
+				"timestamp": 1608309189926189,
+				"type": "certificate"
+			},
+			{
+				"base64Certificate": "MIIBFTCBvKADAgECAgkA...",
+				"fingerprint": %q,
+				"fingerprintAlgorithm": "sha-256",
+				"id": "RTCCertificate_remote",
+				"timestamp": 1608309189926189,
+				"type": "certificate"
+			}
+		]
+	}`, transports, localFingerprint, remoteFingerprint)
+}
+
+const certificateLocalFingerprint = "D0:FF:4F:85:E1:67:31:83"
+
+const certificateTransport = `{
+				"bytesReceived": 1024,
+				"bytesSent": 2048,
+				"dtlsState": "connected",
+				"id": "RTCTransport_0_1",
+				"localCertificateId": "RTCCertificate_local",
+				"remoteCertificateId": "RTCCertificate_remote",
+				"timestamp": 1608309189926189,
+				"type": "transport"
+			},`
+
+func TestCertificate(t *testing.T) {
+	compare(t, certificateResponse(certificateTransport, certificateLocalFingerprint, "C0:92:CF:1A:63:65:5B:93"), "certificate")
+}
+
+func TestCertificateRemoteFingerprintChanges(t *testing.T) {
+	h := newMomo(nil)
+	defer h.Close()
+	e := newTestExporter(t, h.URL, 5*time.Second)
+
+	closed := strings.Replace(certificateTransport, `"connected"`, `"closed"`, 1)
+	for i, step := range []struct {
+		transports  string
+		local       string
+		fingerprint string
+		want        float64
+	}{
+		{certificateTransport, certificateLocalFingerprint, "C0:92:CF:1A:63:65:5B:93", 0},
+		{certificateTransport, certificateLocalFingerprint, "C0:92:CF:1A:63:65:5B:93", 0},
+		{certificateTransport, certificateLocalFingerprint, "5A:81:F9:57:C0:0E:66:59", 1},
+		// The session ended, so the next remote certificate is not a change.
+		{"", certificateLocalFingerprint, "5A:81:F9:57:C0:0E:66:59", 1},
+		{certificateTransport, certificateLocalFingerprint, "C0:92:CF:1A:63:65:5B:93", 1},
+		// A reconnect between scrapes reuses the transport id with a new
+		// peer connection, which has a new local certificate.
+		{certificateTransport, "1B:2C:3D:4E:5F:60:71:82", "7E:11:D2:0A:9B:34:C5:6F", 1},
+		// DTLS went down, so the session ended too.
+		{closed, "1B:2C:3D:4E:5F:60:71:82", "7E:11:D2:0A:9B:34:C5:6F", 1},
+		{certificateTransport, "1B:2C:3D:4E:5F:60:71:82", "C0:92:CF:1A:63:65:5B:93", 1},
+	} {
+		h.response = []byte(certificateResponse(step.transports, step.local, step.fingerprint))
+		testutil.CollectAndCount(e)
+		if got := testutil.ToFloat64(e.remoteCertificateChanges); got != step.want {
+			t.Errorf("step %d: remote fingerprint changes = %v; want %v", i, got, step.want)
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package main
+
+// compatRule fills a field that newer libwebrtc builds report from the field
+// older builds report instead. Rules never overwrite a field that is already
+// present, so they are safe to apply when the milestone is unknown.
+type compatRule struct {
+	// types are the stats types the rule applies to.
+	types []string
+	// until is the first milestone that no longer needs the rule.
+	until int
+	// from is the legacy field and to the current one.
+	from, to string
+	// ref means from holds the id of another stats object whose to field
+	// is copied, rather than the value itself.
+	ref bool
+}
+
+var rtpStatsTypes = []string{"inbound-rtp", "outbound-rtp", "remote-inbound-rtp", "remote-outbound-rtp"}
+
+var compatRules = []compatRule{
+	// RTCRtpStreamStats.mediaType was superseded by kind.
+	{types: rtpStatsTypes, until: 110, from: "mediaType", to: "kind"},
+	// RTCDataChannelStats.datachannelid was renamed to dataChannelIdentifier.
+	{types: []string{"data-channel"}, until: 80, from: "datachannelid", to: "dataChannelIdentifier"},
+	// RTP streams referenced the legacy track stats through trackId before
+	// reporting trackIdentifier themselves.
+	{types: []string{"inbound-rtp", "outbound-rtp"}, until: 112, from: "trackId", to: "trackIdentifier", ref: true},
+}
+
+// statsIndex maps stats ids to the stats objects decoded from Momo.
+type statsIndex map[string]map[string]interface{}
+
+func newStatsIndex(stats []interface{}) statsIndex {
+	index := make(statsIndex, len(stats))
+	for _, s := range stats {
+		if m, ok := s.(map[string]interface{}); ok {
+			if id, ok := m["id"].(string); ok {
+				index[id] = m
+			}
+		}
+	}
+	return index
+}
+
+// normalizeStats applies the compatRules for the given libwebrtc milestone
+// to stats in place. A milestone of 0 means unknown and applies every rule.
+func normalizeStats(stats []interface{}, index statsIndex, milestone int) {
+	for _, r := range compatRules {
+		if milestone != 0 && milestone >= r.until {
+			continue
+		}
+		for _, s := range stats {
+			m, ok := s.(map[string]interface{})
+			if !ok || !r.appliesTo(m) {
+				continue
+			}
+			if _, ok := m[r.to]; ok {
+				continue
+			}
+			v, ok := m[r.from]
+			if !ok {
+				continue
+			}
+			if r.ref {
+				id, _ := v.(string)
+				target, ok := index[id]
+				if !ok {
+					continue
+				}
+				if v, ok = target[r.to]; !ok {
+					continue
+				}
+			}
+			m[r.to] = v
+		}
+	}
+}
+
+func (r compatRule) appliesTo(stats map[string]interface{}) bool {
+	t, _ := stats["type"].(string)
+	for _, rt := range r.types {
+		if rt == t {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestNormalizeStats(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		milestone int
+		stats     string
+		want      string
+	}{
+		{
+			name:      "Momo 2020.1 (M79)",
+			milestone: 79,
+			stats: `[
+				{"id": "RTCInboundRTPVideoStream_1", "type": "inbound-rtp", "mediaType": "video", "trackId": "RTCMediaStreamTrack_receiver_1"},
+				{"id": "RTCMediaStreamTrack_receiver_1", "type": "track", "trackIdentifier": "f2c3", "kind": "video"},
+				{"id": "RTCDataChannel_1", "type": "data-channel", "datachannelid": 1}
+			]`,
+			want: `[
+				{"id": "RTCInboundRTPVideoStream_1", "type": "inbound-rtp", "mediaType": "video", "kind": "video", "trackId": "RTCMediaStreamTrack_receiver_1", "trackIdentifier": "f2c3"},
+				{"id": "RTCMediaStreamTrack_receiver_1", "type": "track", "trackIdentifier": "f2c3", "kind": "video"},
+				{"id": "RTCDataChannel_1", "type": "data-channel", "datachannelid": 1, "dataChannelIdentifier": 1}
+			]`,
+		},
+		{
+			name:      "Momo 2020.11 (M88)",
+			milestone: 88,
+			stats: `[
+				{"id": "RTCOutboundRTPAudioStream_1", "type": "outbound-rtp", "mediaType": "audio", "kind": "audio", "trackId": "RTCMediaStreamTrack_sender_1"},
+				{"id": "RTCMediaStreamTrack_sender_1", "type": "track", "trackIdentifier": "a9e0", "kind": "audio"},
+				{"id": "RTCDataChannel_1", "type": "data-channel", "datachannelid": 2, "dataChannelIdentifier": 1}
+			]`,
+			want: `[
+				{"id": "RTCOutboundRTPAudioStream_1", "type": "outbound-rtp", "mediaType": "audio", "kind": "audio", "trackId": "RTCMediaStreamTrack_sender_1", "trackIdentifier": "a9e0"},
+				{"id": "RTCMediaStreamTrack_sender_1", "type": "track", "trackIdentifier": "a9e0", "kind": "audio"},
+				{"id": "RTCDataChannel_1", "type": "data-channel", "datachannelid": 2, "dataChannelIdentifier": 1}
+			]`,
+		},
+		{
+			name:      "Momo 2023.1 (M112)",
+			milestone: 112,
+			stats: `[
+				{"id": "IT01V1", "type": "inbound-rtp", "mediaType": "audio", "trackId": "missing"}
+			]`,
+			want: `[
+				{"id": "IT01V1", "type": "inbound-rtp", "mediaType": "audio", "trackId": "missing"}
+			]`,
+		},
+		{
+			name: "unknown milestone",
+			stats: `[
+				{"id": "RTCInboundRTPVideoStream_1", "type": "inbound-rtp", "mediaType": "video", "trackId": "missing"},
+				{"id": "RTCTransport_0_1", "type": "transport", "mediaType": "video"}
+			]`,
+			want: `[
+				{"id": "RTCInboundRTPVideoStream_1", "type": "inbound-rtp", "mediaType": "video", "kind": "video", "trackId": "missing"},
+				{"id": "RTCTransport_0_1", "type": "transport", "mediaType": "video"}
+			]`,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var stats, want []interface{}
+			if err := json.Unmarshal([]byte(tc.stats), &stats); err != nil {
+				t.Fatal(err)
+			}
+			if err := json.Unmarshal([]byte(tc.want), &want); err != nil {
+				t.Fatal(err)
+			}
+			normalizeStats(stats, newStatsIndex(stats), tc.milestone)
+			if !reflect.DeepEqual(stats, want) {
+				t.Errorf("normalizeStats() = %v; want %v", stats, want)
+			}
+		})
+	}
+}
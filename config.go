@@ -0,0 +1,214 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"time"
+
+	"github.com/prometheus/common/model"
+	"gopkg.in/yaml.v2"
+)
+
+const defaultTimeout = model.Duration(5 * time.Second)
+
+// Config is the configuration file of the exporter.
+type Config struct {
+	Targets       []TargetConfig `yaml:"targets,omitempty"`
+	FileSDConfigs []FileSDConfig `yaml:"file_sd_configs,omitempty"`
+	ProcSDConfigs []ProcSDConfig `yaml:"proc_sd_configs,omitempty"`
+}
+
+// TargetConfig configures a single WebRTC Native Client Momo to scrape.
+type TargetConfig struct {
+	// Name identifies the target in the target label. It defaults to URI.
+	Name    string            `yaml:"name,omitempty"`
+	URI     string            `yaml:"uri"`
+	Timeout model.Duration    `yaml:"timeout,omitempty"`
+	Labels  map[string]string `yaml:"labels,omitempty"`
+
+	ExportConfig     ExportConfig     `yaml:",inline"`
+	HTTPClientConfig HTTPClientConfig `yaml:",inline"`
+
+	// pid is the process ID of the Momo serving the target, when it was
+	// found by process discovery, and procFS is where its /proc is mounted.
+	pid    int
+	procFS string
+}
+
+// ExportConfig configures which metrics are exported for a target and how.
+type ExportConfig struct {
+	// StatsTypes limits the exported WebRTC stats types. It defaults to all
+	// supported stats types.
+	StatsTypes              []string                 `yaml:"stats_types,omitempty"`
+	TransportSecurityPolicy *TransportSecurityPolicy `yaml:"transport_security_policy,omitempty"`
+	// DataChannelLabels adds the named data channel fields as labels.
+	DataChannelLabels []string `yaml:"data_channel_labels,omitempty"`
+	// RTPAggregate exports the RTP stream counters summed per kind.
+	RTPAggregate *RTPAggregateConfig `yaml:"rtp_aggregate,omitempty"`
+	MetricFilter *MetricFilter       `yaml:"metric_filter,omitempty"`
+	// RelabelConfigs rewrite the labels of the exported metrics.
+	RelabelConfigs []*RelabelConfig `yaml:"relabel_configs,omitempty"`
+	// GenericStats also exports the numeric stats fields that have no
+	// dedicated metric.
+	GenericStats *GenericStatsConfig `yaml:"generic_stats,omitempty"`
+}
+
+// Validate checks the export configuration for errors.
+func (c *ExportConfig) Validate() error {
+	for _, st := range c.StatsTypes {
+		if !knownStatsType(st) && (c.GenericStats == nil || !genericStatsTypes[st]) {
+			return fmt.Errorf("unknown stats type %q", st)
+		}
+	}
+	if err := validateDataChannelLabels(c.DataChannelLabels); err != nil {
+		return err
+	}
+	for _, r := range c.RelabelConfigs {
+		if err := r.Validate(); err != nil {
+			return err
+		}
+	}
+	return c.MetricFilter.Validate()
+}
+
+// LoadConfig parses and validates the configuration file filename.
+func LoadConfig(filename string) (*Config, error) {
+	b, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := &Config{}
+	if err := yaml.UnmarshalStrict(b, cfg); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", filename, err)
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid config %s: %w", filename, err)
+	}
+	return cfg, nil
+}
+
+// Validate fills in defaults and checks the configuration for errors.
+func (c *Config) Validate() error {
+	names := make(map[string]bool, len(c.Targets))
+	for i := range c.Targets {
+		t := &c.Targets[i]
+		if err := t.Validate(); err != nil {
+			return err
+		}
+		if names[t.Name] {
+			return fmt.Errorf("duplicate target name %q", t.Name)
+		}
+		names[t.Name] = true
+	}
+	for i := range c.Targets {
+		if err := checkConsistentTarget(c.Targets[:i], &c.Targets[i]); err != nil {
+			return err
+		}
+	}
+	for i := range c.FileSDConfigs {
+		if err := c.FileSDConfigs[i].Validate(); err != nil {
+			return fmt.Errorf("file_sd_configs[%d]: %w", i, err)
+		}
+	}
+	for i := range c.ProcSDConfigs {
+		if err := c.ProcSDConfigs[i].Validate(); err != nil {
+			return fmt.Errorf("proc_sd_configs[%d]: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// reservedLabelNames are the label names of the exported metrics, which
+// static labels of a target must not use.
+var reservedLabelNames = func() map[string]bool {
+	names := map[string]bool{}
+	for _, l := range [][]string{
+		{"target", "le", "quantile"},
+		dataChannelLabelNames, dataChannelOptionalLabels, inboundRTPLabelNames, mediaPlayoutLabelNames,
+		outboundRTPLabelNames, peerConnectionLabelNames, transportLabelNames, trackLabelNames,
+		transportSecurityLabelNames, layerSummaryLabelNames,
+		{"state", "reason", "codec", "type", "field"},
+		{"version", "environment", "libwebrtc"},
+		{"release", "commit", "libwebrtc_milestone", "libwebrtc_branch", "libwebrtc_build", "libwebrtc_hash"},
+		{"arch", "os", "os_version", "platform_package", "platform_version"},
+		{"fingerprint_algorithm", "fingerprint", "role"},
+		{"from_type", "from_id", "to_type", "to_id", "relation"},
+	} {
+		for _, name := range l {
+			names[name] = true
+		}
+	}
+	return names
+}()
+
+// checkConsistentTarget checks that t exports the same label names for each
+// metric as the targets, as required to export them together.
+func checkConsistentTarget(targets []TargetConfig, t *TargetConfig) error {
+	for i := range targets {
+		other := &targets[i]
+		if !sameLabelSet(other.ExportConfig.DataChannelLabels, t.ExportConfig.DataChannelLabels) {
+			return fmt.Errorf("target %q: data_channel_labels must be the same as for target %q", t.Name, other.Name)
+		}
+		if a, b := other.ExportConfig.RTPAggregate, t.ExportConfig.RTPAggregate; a != nil && b != nil && a.ByCodec != b.ByCodec {
+			return fmt.Errorf("target %q: rtp_aggregate.by_codec must be the same as for target %q", t.Name, other.Name)
+		}
+	}
+	return nil
+}
+
+func sameLabelSet(a, b []string) bool {
+	set := make(map[string]bool, len(a))
+	for _, l := range a {
+		set[l] = true
+	}
+	for _, l := range b {
+		if !set[l] {
+			return false
+		}
+		delete(set, l)
+	}
+	return len(set) == 0
+}
+
+// Validate fills in defaults and checks the target configuration for errors.
+func (t *TargetConfig) Validate() error {
+	if t.URI == "" {
+		return fmt.Errorf("target %q: uri must be set", t.Name)
+	}
+	if _, err := url.ParseRequestURI(t.URI); err != nil {
+		return fmt.Errorf("target %q: %w", t.Name, err)
+	}
+	if t.Name == "" {
+		t.Name = t.URI
+	}
+	if t.Timeout == 0 {
+		t.Timeout = defaultTimeout
+	}
+	for name := range t.Labels {
+		if !model.LabelName(name).IsValid() {
+			return fmt.Errorf("target %q: invalid label name %q", t.Name, name)
+		}
+		if reservedLabelNames[name] {
+			return fmt.Errorf("target %q: label %q is reserved", t.Name, name)
+		}
+	}
+	if err := t.ExportConfig.Validate(); err != nil {
+		return fmt.Errorf("target %q: %w", t.Name, err)
+	}
+	if err := t.HTTPClientConfig.Validate(); err != nil {
+		return fmt.Errorf("target %q: %w", t.Name, err)
+	}
+	return nil
+}
+
+// labels returns the labels added to every metric of the target.
+func (t *TargetConfig) labels() map[string]string {
+	labels := make(map[string]string, len(t.Labels)+1)
+	for name, value := range t.Labels {
+		labels[name] = value
+	}
+	labels["target"] = t.Name
+	return labels
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"crypto/tls"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/prometheus/common/expfmt"
+	"github.com/prometheus/common/model"
+)
+
+func TestLoadConfig(t *testing.T) {
+	cfg, err := LoadConfig("test/config.yml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := &Config{
+		Targets: []TargetConfig{
+			{
+				Name:    "robot-1",
+				URI:     "http://robot-1:8081/metrics",
+				Timeout: model.Duration(5 * time.Second),
+				Labels:  map[string]string{"site": "lab"},
+				ExportConfig: ExportConfig{
+					StatsTypes: []string{"inbound-rtp", "outbound-rtp"},
+					TransportSecurityPolicy: &TransportSecurityPolicy{
+						SRTPCiphers:    []string{"AEAD_AES_128_GCM", "AEAD_AES_256_GCM"},
+						MinDTLSVersion: DTLSVersions["DTLS12"],
+					},
+				},
+			},
+			{
+				Name:    "https://robot-2:8081/metrics",
+				URI:     "https://robot-2:8081/metrics",
+				Timeout: model.Duration(10 * time.Second),
+				HTTPClientConfig: HTTPClientConfig{
+					BasicAuth: &BasicAuth{Username: "momo", PasswordFile: "/etc/momo_exporter/password"},
+					Headers:   map[string]string{"X-Robot-Id": "robot-2"},
+					TLSConfig: TLSConfig{
+						CAFile:     "/etc/pki/device-ca.pem",
+						ServerName: "momo.local",
+						MinVersion: tls.VersionTLS12,
+					},
+					ProxyURL: "socks5://bastion:1080",
+				},
+			},
+		},
+	}
+	if !reflect.DeepEqual(cfg, want) {
+		t.Errorf("want config %+v, have %+v", want, cfg)
+	}
+}
+
+func TestLoadConfigErrors(t *testing.T) {
+	for name, content := range map[string]string{
+		"unknown field":              "targets:\n  - uri: http://localhost:8081/metrics\n    unknown: true\n",
+		"missing uri":                "targets:\n  - name: robot-1\n",
+		"duplicate name":             "targets:\n  - name: robot\n    uri: http://a/metrics\n  - name: robot\n    uri: http://b/metrics\n",
+		"reserved label":             "targets:\n  - uri: http://localhost:8081/metrics\n    labels:\n      target: x\n",
+		"invalid label":              "targets:\n  - uri: http://localhost:8081/metrics\n    labels:\n      not-valid: x\n",
+		"unknown stats type":         "targets:\n  - uri: http://localhost:8081/metrics\n    stats_types: [codec]\n",
+		"unknown TLS version":        "targets:\n  - uri: http://localhost:8081/metrics\n    tls_config:\n      min_version: SSL3\n",
+		"unknown DTLS version":       "targets:\n  - uri: http://localhost:8081/metrics\n    transport_security_policy:\n      min_dtls_version: DTLS11\n",
+		"two auth methods":           "targets:\n  - uri: http://localhost:8081/metrics\n    bearer_token: a\n    basic_auth:\n      username: b\n",
+		"metric label":               "targets:\n  - uri: http://localhost:8081/metrics\n    labels:\n      kind: x\n",
+		"data channel labels":        "targets:\n  - name: a\n    uri: http://a/metrics\n    data_channel_labels: [protocol]\n  - name: b\n    uri: http://b/metrics\n",
+		"unknown generic stats type": "targets:\n  - uri: http://localhost:8081/metrics\n    stats_types: [inbound_rtp]\n    generic_stats: {}\n",
+		"by_codec":                   "targets:\n  - name: a\n    uri: http://a/metrics\n    rtp_aggregate: {by_codec: true}\n  - name: b\n    uri: http://b/metrics\n    rtp_aggregate: {}\n",
+	} {
+		filename := filepath.Join(t.TempDir(), "config.yml")
+		writeFile(t, filename, content)
+		if _, err := LoadConfig(filename); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
+
+// TestReservedLabelNames checks that static labels cannot collide with the
+// labels of the metrics in the test fixtures.
+func TestReservedLabelNames(t *testing.T) {
+	files, err := filepath.Glob("test/*.metrics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, file := range files {
+		f, err := os.Open(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		families, err := new(expfmt.TextParser).TextToMetricFamilies(f)
+		f.Close()
+		if err != nil {
+			t.Fatalf("%s: %v", file, err)
+		}
+		for _, mf := range families {
+			for _, m := range mf.Metric {
+				for _, l := range m.Label {
+					if !reservedLabelNames[l.GetName()] {
+						t.Errorf("%s: label %q of %s is not reserved", file, l.GetName(), mf.GetName())
+					}
+				}
+			}
+		}
+	}
+}
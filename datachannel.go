@@ -0,0 +1,130 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/iancoleman/strcase"
+	"github.com/koron/go-dproxy"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// dataChannelOptionalLabels are the data channel fields that can be added as
+// labels with ExportConfig.DataChannelLabels.
+var dataChannelOptionalLabels = []string{"protocol", "dataChannelIdentifier"}
+
+// https://www.w3.org/TR/webrtc-stats/#dom-rtcdatachannelstate
+var dataChannelStates = []string{"connecting", "open", "closing", "closed"}
+
+// dataChannelDescs holds the descriptors of the data channel metrics for one
+// set of label names. The rates and state leave out the metrics denied by the
+// metric filter; state is empty if it is denied.
+type dataChannelDescs struct {
+	labelNames []string
+	metrics    metrics
+	rates      metrics
+	state      metricInfo
+}
+
+func newDataChannelDescs(extraLabels []string, filter *MetricFilter) dataChannelDescs {
+	labelNames := append(append([]string{}, dataChannelLabelNames...), extraLabels...)
+	d := dataChannelDescs{
+		labelNames: labelNames,
+		metrics:    newDataChannelMetrics(labelNames),
+		rates:      metrics{},
+		state: newDataChannelMetric("state", "State of this RTCDataChannel; 1 for the current state.", prometheus.GaugeValue,
+			append(append([]string{}, labelNames...), "state")),
+	}
+	for key, m := range (metrics{
+		"bytesSent":        newDataChannelMetric("bytes_sent_per_second", "Payload bytes sent per second on this RTCDataChannel since the previous scrape.", prometheus.GaugeValue, labelNames),
+		"bytesReceived":    newDataChannelMetric("bytes_received_per_second", "Payload bytes received per second on this RTCDataChannel since the previous scrape.", prometheus.GaugeValue, labelNames),
+		"messagesSent":     newDataChannelMetric("messages_sent_per_second", "API \"message\" events sent per second since the previous scrape.", prometheus.GaugeValue, labelNames),
+		"messagesReceived": newDataChannelMetric("messages_received_per_second", "API \"message\" events received per second since the previous scrape.", prometheus.GaugeValue, labelNames),
+	}) {
+		if filter.metricAllowed(m.FQName) {
+			d.rates[key] = m
+		}
+	}
+	if !filter.metricAllowed(d.state.FQName) {
+		d.state = metricInfo{}
+	}
+	return d
+}
+
+func (d dataChannelDescs) describe(ch chan<- *prometheus.Desc) {
+	for _, m := range d.rates {
+		ch <- m.Desc
+	}
+	if d.state.Desc != nil {
+		ch <- d.state.Desc
+	}
+}
+
+// validateDataChannelLabels checks that labels only names optional data
+// channel labels.
+func validateDataChannelLabels(labels []string) error {
+	for _, l := range labels {
+		known := false
+		for _, o := range dataChannelOptionalLabels {
+			known = known || l == o
+		}
+		if !known {
+			return fmt.Errorf("unknown data channel label %q", l)
+		}
+	}
+	return nil
+}
+
+// dataChannelSample holds the counters of a data channel at a stats timestamp.
+type dataChannelSample struct {
+	timestamp float64
+	values    map[string]float64
+}
+
+func (e *Exporter) exportDataChannelMetrics(m dproxy.Proxy, ch chan<- prometheus.Metric) {
+	d := e.dataChannel
+	labels := make([]string, len(d.labelNames))
+	for i, name := range d.labelNames {
+		labels[i] = labelValue(m, name)
+	}
+
+	// The counters are sampled even if the metric filter drops them, as the
+	// rates are computed from them.
+	table := e.metricsFor("data-channel")
+	sample := dataChannelSample{values: make(map[string]float64, len(d.metrics))}
+	// Stats timestamps from libwebrtc are in microseconds.
+	sample.timestamp, _ = m.M("timestamp").Float64()
+	for key := range d.metrics {
+		val, _ := m.M(strcase.ToLowerCamel(key)).Float64()
+		sample.values[key] = val
+		if metric, ok := table[key]; ok {
+			ch <- prometheus.MustNewConstMetric(metric.Desc, metric.Type, val, labels...)
+		}
+	}
+
+	if d.state.Desc != nil {
+		state, _ := m.M("state").String()
+		for _, s := range dataChannelStates {
+			var val float64
+			if s == state {
+				val = 1
+			}
+			ch <- prometheus.MustNewConstMetric(d.state.Desc, prometheus.GaugeValue, val, append(labels, s)...)
+		}
+	}
+
+	id, _ := m.M("id").String()
+	last, ok := e.lastDataChannelSamples[id]
+	e.dataChannelSamples[id] = sample
+	if !ok || sample.timestamp <= last.timestamp {
+		return
+	}
+	elapsed := (sample.timestamp - last.timestamp) / 1e6
+	for key, metric := range d.rates {
+		delta := sample.values[key] - last.values[key]
+		if delta < 0 {
+			// The channel was recreated with the same id.
+			continue
+		}
+		ch <- prometheus.MustNewConstMetric(metric.Desc, metric.Type, delta/elapsed, labels...)
+	}
+}
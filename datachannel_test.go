@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/common/model"
+)
+
+func dataChannelResponse(timestamp int64, bytesSent, messagesSent int) string {
+	return fmt.Sprintf(`{
+		"version": "WebRTC Native Client Momo 2020.11 (db9d97e)",
+		"libwebrtc": "Shiguredo-Build M88.4324@{#2} (88.4324.2.0 54bd8488)",
+		"environment": "[aarch64] Ubuntu 18.04.5 LTS (nvidia-l4t-core 32.4.4-20201016123640)",
+		"stats": [
+			{
+				"bytesReceived": 10,
+				"bytesSent": %d,
+				"dataChannelIdentifier": 1,
+				"id": "RTCDataChannel_1",
+				"label": "serial",
+				"messagesReceived": 1,
+				"messagesSent": %d,
+				"protocol": "modbus",
+				"state": "open",
+				"timestamp": %d,
+				"type": "data-channel"
+			}
+		]
+	}`, bytesSent, messagesSent, timestamp)
+}
+
+func TestDataChannelRates(t *testing.T) {
+	h := newMomo([]byte(dataChannelResponse(1608309189926189, 20, 2)))
+	defer h.Close()
+	e, err := NewExporter(TargetConfig{
+		URI:     h.URL,
+		Timeout: model.Duration(5 * time.Second),
+		ExportConfig: ExportConfig{
+			StatsTypes:        []string{"data-channel"},
+			DataChannelLabels: []string{"protocol", "dataChannelIdentifier"},
+		},
+	}, log.NewNopLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	e.now = func() time.Time { return testTime }
+
+	testutil.CollectAndCount(e)
+	// Two seconds later.
+	h.response = []byte(dataChannelResponse(1608309191926189, 4020, 42))
+	expectMetrics(t, e, "data_channel_rates")
+}
+
+func TestValidateDataChannelLabels(t *testing.T) {
+	if err := validateDataChannelLabels([]string{"protocol", "dataChannelIdentifier"}); err != nil {
+		t.Error(err)
+	}
+	if err := validateDataChannelLabels([]string{"state"}); err == nil {
+		t.Error("expected error for unknown label")
+	}
+}
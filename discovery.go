@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+)
+
+// discoverer periodically refreshes the targets that a discovery mechanism
+// provides to a targetManager under its source name.
+type discoverer struct {
+	source   string
+	interval time.Duration
+	discover func() []TargetConfig
+	targets  *targetManager
+	logger   log.Logger
+
+	last []TargetConfig
+}
+
+func newDiscoverer(source string, interval time.Duration, discover func() []TargetConfig, targets *targetManager, logger log.Logger) *discoverer {
+	return &discoverer{
+		source:   source,
+		interval: interval,
+		discover: discover,
+		targets:  targets,
+		logger:   log.With(logger, "discovery", source),
+	}
+}
+
+// run refreshes the targets every interval until ctx is done, and updates
+// the targets of the source when they change.
+func (d *discoverer) run(ctx context.Context) {
+	ticker := time.NewTicker(d.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		targets := d.discover()
+		if reflect.DeepEqual(targets, d.last) {
+			continue
+		}
+		if err := d.targets.Update(d.source, targets); err != nil {
+			level.Error(d.logger).Log("msg", "Error updating discovered targets", "err", err)
+			continue
+		}
+		level.Info(d.logger).Log("msg", "Discovered targets changed", "targets", len(targets))
+		d.last = targets
+	}
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+)
+
+func TestDiscovererRun(t *testing.T) {
+	var (
+		mtx        sync.Mutex
+		discovered []TargetConfig
+	)
+	setDiscovered := func(names ...string) {
+		mtx.Lock()
+		defer mtx.Unlock()
+		discovered = nil
+		for _, name := range names {
+			discovered = append(discovered, TargetConfig{Name: name, URI: "http://" + name + "/metrics"})
+		}
+	}
+	discover := func() []TargetConfig {
+		mtx.Lock()
+		defer mtx.Unlock()
+		return discovered
+	}
+
+	m := newTargetManager(log.NewNopLogger())
+	d := newDiscoverer("test", 10*time.Millisecond, discover, m, log.NewNopLogger())
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go d.run(ctx)
+
+	hasTargets := func(names ...string) bool {
+		m.mtx.RLock()
+		defer m.mtx.RUnlock()
+		if len(m.targets) != len(names) {
+			return false
+		}
+		for _, name := range names {
+			if _, ok := m.targets[name]; !ok {
+				return false
+			}
+		}
+		return true
+	}
+	waitFor := func(names ...string) {
+		deadline := time.Now().Add(5 * time.Second)
+		for !hasTargets(names...) {
+			if time.Now().After(deadline) {
+				t.Fatalf("timed out waiting for targets %v", names)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+
+	setDiscovered("robot-1:8081")
+	waitFor("robot-1:8081")
+	setDiscovered("robot-2:8081", "robot-3:8081")
+	waitFor("robot-2:8081", "robot-3:8081")
+	setDiscovered()
+	waitFor()
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	// e.g. "[aarch64] Ubuntu 18.04.5 LTS (nvidia-l4t-core 32.4.4-20201016123640)"
+	environmentRE = regexp.MustCompile(`^\[([^\]]*)\]\s*(.*)$`)
+	// A trailing "(name version)", such as the L4T core package or the macOS build.
+	environmentPackageRE = regexp.MustCompile(`\s*\(([^\s()]+) ([^\s()]+)\)$`)
+)
+
+var environmentInfo = newDesc("environment", "info",
+	"WebRTC Native Client Momo platform parsed from the environment string.",
+	[]string{"arch", "os", "os_version", "platform_package", "platform_version"})
+
+// environment is a parsed Momo environment string.
+type environment struct {
+	Arch            string
+	OS              string
+	OSVersion       string
+	PlatformPackage string
+	PlatformVersion string
+}
+
+// parseEnvironment parses the environment reported by Momo, which is the
+// architecture in brackets, followed by the OS name and version and an
+// optional "(package version)" of the platform. Parts that are missing are
+// left empty.
+func parseEnvironment(s string) environment {
+	var env environment
+	rest := strings.TrimSpace(s)
+	if m := environmentRE.FindStringSubmatch(rest); m != nil {
+		env.Arch = m[1]
+		rest = m[2]
+	}
+	if m := environmentPackageRE.FindStringSubmatchIndex(rest); m != nil {
+		name, version := rest[m[2]:m[3]], rest[m[4]:m[5]]
+		// macOS reports "(Build 19H15)", which is part of the OS version
+		// rather than a platform package.
+		if name != "Build" {
+			env.PlatformPackage, env.PlatformVersion = name, version
+		}
+		rest = rest[:m[0]]
+	}
+
+	var name, version []string
+	for _, f := range strings.Fields(rest) {
+		switch {
+		case len(version) > 0:
+			version = append(version, f)
+		case f == "Version":
+		case unicode.IsDigit(rune(f[0])):
+			version = append(version, f)
+		default:
+			name = append(name, f)
+		}
+	}
+	env.OS = strings.Join(name, " ")
+	env.OSVersion = strings.Join(version, " ")
+	return env
+}
+
+func (e *Exporter) exportEnvironmentMetrics(metrics MomoMetrics, ch chan<- prometheus.Metric) {
+	env := parseEnvironment(metrics.Environment)
+	e.sendConst(ch, environmentInfo, prometheus.GaugeValue, 1,
+		env.Arch, env.OS, env.OSVersion, env.PlatformPackage, env.PlatformVersion)
+}
@@ -0,0 +1,36 @@
+package main
+
+import "testing"
+
+func TestParseEnvironment(t *testing.T) {
+	for _, tc := range []struct {
+		in   string
+		want environment
+	}{
+		{
+			"[aarch64] Ubuntu 18.04.5 LTS (nvidia-l4t-core 32.4.4-20201016123640)",
+			environment{Arch: "aarch64", OS: "Ubuntu", OSVersion: "18.04.5 LTS", PlatformPackage: "nvidia-l4t-core", PlatformVersion: "32.4.4-20201016123640"},
+		},
+		{
+			"[x86_64] macOS Version 10.15.7 (Build 19H15)",
+			environment{Arch: "x86_64", OS: "macOS", OSVersion: "10.15.7"},
+		},
+		{
+			"[armv7l] Raspbian GNU/Linux 10 (buster) (raspberrypi-kernel 1.20201126-1)",
+			environment{Arch: "armv7l", OS: "Raspbian GNU/Linux", OSVersion: "10 (buster)", PlatformPackage: "raspberrypi-kernel", PlatformVersion: "1.20201126-1"},
+		},
+		{
+			"[x86_64] Ubuntu 20.04.1 LTS",
+			environment{Arch: "x86_64", OS: "Ubuntu", OSVersion: "20.04.1 LTS"},
+		},
+		{
+			"Test Environment",
+			environment{OS: "Test Environment"},
+		},
+		{"", environment{}},
+	} {
+		if got := parseEnvironment(tc.in); got != tc.want {
+			t.Errorf("parseEnvironment(%q) = %+v; want %+v", tc.in, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/prometheus/common/model"
+	"gopkg.in/yaml.v2"
+)
+
+const defaultRefreshInterval = model.Duration(30 * time.Second)
+
+// FileSDConfig configures targets discovered from JSON or YAML files in the
+// Prometheus file_sd format. The settings other than Files and
+// RefreshInterval apply to every discovered target.
+type FileSDConfig struct {
+	Files           []string       `yaml:"files"`
+	RefreshInterval model.Duration `yaml:"refresh_interval,omitempty"`
+	Scheme          string         `yaml:"scheme,omitempty"`
+	MetricsPath     string         `yaml:"metrics_path,omitempty"`
+	Timeout         model.Duration `yaml:"timeout,omitempty"`
+
+	ExportConfig     ExportConfig     `yaml:",inline"`
+	HTTPClientConfig HTTPClientConfig `yaml:",inline"`
+}
+
+// Validate fills in defaults and checks the configuration for errors.
+func (c *FileSDConfig) Validate() error {
+	if len(c.Files) == 0 {
+		return fmt.Errorf("file_sd config must contain at least one file")
+	}
+	for _, pattern := range c.Files {
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid file pattern %q: %w", pattern, err)
+		}
+	}
+	if c.RefreshInterval == 0 {
+		c.RefreshInterval = defaultRefreshInterval
+	}
+	if c.Scheme == "" {
+		c.Scheme = "http"
+	}
+	if c.MetricsPath == "" {
+		c.MetricsPath = "/metrics"
+	}
+	// Validate the settings shared by all targets once, on a placeholder.
+	t := c.target("localhost:8081", nil)
+	return t.Validate()
+}
+
+// target returns the configuration of a discovered target. The __scheme__
+// and __metrics_path__ labels override the configured scheme and path;
+// other labels starting with __ are dropped.
+func (c *FileSDConfig) target(address string, groupLabels map[string]string) TargetConfig {
+	scheme, path := c.Scheme, c.MetricsPath
+	var labels map[string]string
+	for name, value := range groupLabels {
+		switch {
+		case name == "__scheme__":
+			scheme = value
+		case name == "__metrics_path__":
+			path = value
+		case strings.HasPrefix(name, "__"):
+		default:
+			if labels == nil {
+				labels = map[string]string{}
+			}
+			labels[name] = value
+		}
+	}
+	return TargetConfig{
+		Name:             address,
+		URI:              scheme + "://" + address + path,
+		Timeout:          c.Timeout,
+		Labels:           labels,
+		ExportConfig:     c.ExportConfig,
+		HTTPClientConfig: c.HTTPClientConfig,
+	}
+}
+
+// targetGroup is an entry of a file_sd file.
+type targetGroup struct {
+	Targets []string          `yaml:"targets" json:"targets"`
+	Labels  map[string]string `yaml:"labels,omitempty" json:"labels,omitempty"`
+}
+
+// fileDiscoverer lists the targets in the files of a FileSDConfig.
+type fileDiscoverer struct {
+	config FileSDConfig
+	logger log.Logger
+
+	// fileTargets caches the targets of each file, so that a file which
+	// cannot be read, e.g. while it is being rewritten, keeps its targets.
+	fileTargets map[string][]TargetConfig
+}
+
+func newFileDiscoverer(config FileSDConfig, logger log.Logger) *fileDiscoverer {
+	return &fileDiscoverer{
+		config:      config,
+		logger:      logger,
+		fileTargets: map[string][]TargetConfig{},
+	}
+}
+
+// discover returns the targets currently listed in the files.
+func (d *fileDiscoverer) discover() []TargetConfig {
+	var filenames []string
+	for _, pattern := range d.config.Files {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			level.Error(d.logger).Log("msg", "Error expanding file pattern", "pattern", pattern, "err", err)
+			continue
+		}
+		filenames = append(filenames, matches...)
+	}
+
+	fileTargets := make(map[string][]TargetConfig, len(filenames))
+	var targets []TargetConfig
+	seen := map[string]bool{}
+	for _, filename := range filenames {
+		ts, err := d.readFile(filename)
+		if err != nil {
+			level.Error(d.logger).Log("msg", "Error reading file_sd file", "file", filename, "err", err)
+			ts = d.fileTargets[filename]
+		}
+		fileTargets[filename] = ts
+		for _, t := range ts {
+			if seen[t.Name] {
+				continue
+			}
+			seen[t.Name] = true
+			targets = append(targets, t)
+		}
+	}
+	d.fileTargets = fileTargets
+	return targets
+}
+
+func (d *fileDiscoverer) readFile(filename string) ([]TargetConfig, error) {
+	b, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	var groups []targetGroup
+	switch ext := filepath.Ext(filename); strings.ToLower(ext) {
+	case ".json":
+		err = json.Unmarshal(b, &groups)
+	case ".yml", ".yaml":
+		err = yaml.UnmarshalStrict(b, &groups)
+	default:
+		return nil, fmt.Errorf("unsupported file extension %q", ext)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var targets []TargetConfig
+	for _, g := range groups {
+		for _, address := range g.Targets {
+			t := d.config.target(address, g.Labels)
+			if err := t.Validate(); err != nil {
+				level.Warn(d.logger).Log("msg", "Ignoring invalid target", "file", filename, "err", err)
+				continue
+			}
+			targets = append(targets, t)
+		}
+	}
+	return targets, nil
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/go-kit/kit/log"
+)
+
+func newTestFileDiscoverer(t *testing.T, files ...string) *fileDiscoverer {
+	c := FileSDConfig{Files: files}
+	if err := c.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	return newFileDiscoverer(c, log.NewNopLogger())
+}
+
+func TestFileSDDiscover(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "robots.json"), `[
+		{"targets": ["robot-1:8081"], "labels": {"site": "lab", "__meta_owner": "ops"}},
+		{"targets": ["robot-2:8443"], "labels": {"__scheme__": "https", "__metrics_path__": "/stats"}}
+	]`)
+	writeFile(t, filepath.Join(dir, "robots.yml"), "- targets: [robot-3:8081, robot-1:8081]\n")
+
+	d := newTestFileDiscoverer(t, filepath.Join(dir, "*.json"), filepath.Join(dir, "*.yml"))
+	want := []TargetConfig{
+		{Name: "robot-1:8081", URI: "http://robot-1:8081/metrics", Timeout: defaultTimeout, Labels: map[string]string{"site": "lab"}},
+		{Name: "robot-2:8443", URI: "https://robot-2:8443/stats", Timeout: defaultTimeout},
+		{Name: "robot-3:8081", URI: "http://robot-3:8081/metrics", Timeout: defaultTimeout},
+	}
+	if have := d.discover(); !reflect.DeepEqual(have, want) {
+		t.Errorf("want targets %+v, have %+v", want, have)
+	}
+}
+
+func TestFileSDKeepsTargetsOfUnreadableFile(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "robots.json")
+	writeFile(t, filename, `[{"targets": ["robot-1:8081"]}]`)
+
+	d := newTestFileDiscoverer(t, filename)
+	want := d.discover()
+	if len(want) != 1 {
+		t.Fatalf("want 1 target, have %d", len(want))
+	}
+
+	writeFile(t, filename, `[{"targets": ["robot-1:80`)
+	if have := d.discover(); !reflect.DeepEqual(have, want) {
+		t.Errorf("want targets %+v, have %+v", want, have)
+	}
+}
+
+func TestFileSDConfigValidate(t *testing.T) {
+	for name, c := range map[string]FileSDConfig{
+		"no files":         {},
+		"invalid pattern":  {Files: []string{"robots/[.json"}},
+		"invalid settings": {Files: []string{"robots.json"}, ExportConfig: ExportConfig{StatsTypes: []string{"codec"}}},
+	} {
+		if err := c.Validate(); err == nil {
+			t.Errorf("%s: expected validation error", name)
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"fmt"
+	"path"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// MetricFilter selects the exported stats types and metrics with glob
+// patterns as matched by path.Match. Empty allow lists allow everything, and
+// deny lists take precedence over allow lists.
+type MetricFilter struct {
+	AllowStatsTypes []string `yaml:"allow_stats_types,omitempty"`
+	DenyStatsTypes  []string `yaml:"deny_stats_types,omitempty"`
+	AllowMetrics    []string `yaml:"allow_metrics,omitempty"`
+	DenyMetrics     []string `yaml:"deny_metrics,omitempty"`
+}
+
+// Validate checks the patterns of the filter.
+func (f *MetricFilter) Validate() error {
+	if f == nil {
+		return nil
+	}
+	for _, patterns := range [][]string{f.AllowStatsTypes, f.DenyStatsTypes, f.AllowMetrics, f.DenyMetrics} {
+		for _, p := range patterns {
+			if _, err := path.Match(p, ""); err != nil {
+				return fmt.Errorf("invalid pattern %q: %w", p, err)
+			}
+		}
+	}
+	return nil
+}
+
+// statsTypeAllowed reports whether stats of type t pass the filter.
+func (f *MetricFilter) statsTypeAllowed(t string) bool {
+	return f == nil || globAllowed(f.AllowStatsTypes, f.DenyStatsTypes, t)
+}
+
+// metricAllowed reports whether the metric named name passes the filter.
+func (f *MetricFilter) metricAllowed(name string) bool {
+	return f == nil || globAllowed(f.AllowMetrics, f.DenyMetrics, name)
+}
+
+func globAllowed(allow, deny []string, s string) bool {
+	if matchAny(deny, s) {
+		return false
+	}
+	return len(allow) == 0 || matchAny(allow, s)
+}
+
+func matchAny(patterns []string, s string) bool {
+	for _, p := range patterns {
+		if ok, _ := path.Match(p, s); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// metricTables returns the metric tables of the stats types for the exporter
+// configuration, leaving out the metrics denied by the metric filter, so
+// that they are never built.
+func (e *Exporter) metricTables() map[string]metrics {
+	tables := make(map[string]metrics, len(statsTypeMetrics))
+	for t, table := range statsTypeMetrics {
+		switch t {
+		case "data-channel":
+			table = e.dataChannel.metrics
+		case "inbound-rtp", "outbound-rtp":
+			if e.dropPerStreamRTP() {
+				table = nil
+			}
+		}
+		filtered := make(metrics, len(table))
+		for key, m := range table {
+			if e.filter.metricAllowed(m.FQName) {
+				filtered[key] = m
+			}
+		}
+		tables[t] = filtered
+	}
+	return tables
+}
+
+// descNames holds the fully-qualified names of the descriptors outside of the
+// metric tables, for the metric filter. It is only written while the package
+// is initialized.
+var descNames = map[*prometheus.Desc]string{}
+
+// newDesc returns the descriptor of the metric named
+// <namespace>_<subsystem>_<name>, outside of the metric tables.
+func newDesc(subsystem, name, help string, labelNames []string) *prometheus.Desc {
+	fqName := prometheus.BuildFQName(namespace, subsystem, name)
+	d := prometheus.NewDesc(fqName, help, labelNames, nil)
+	descNames[d] = fqName
+	return d
+}
+
+// deniedDescs returns the descriptors of descNames denied by the metric
+// filter.
+func (f *MetricFilter) deniedDescs() map[*prometheus.Desc]bool {
+	if f == nil {
+		return nil
+	}
+	denied := map[*prometheus.Desc]bool{}
+	for d, name := range descNames {
+		if !f.metricAllowed(name) {
+			denied[d] = true
+		}
+	}
+	return denied
+}
+
+// allowed reports whether the metric of d, a descriptor outside of the metric
+// tables, passes the metric filter. Metrics are only built if it does.
+func (e *Exporter) allowed(d *prometheus.Desc) bool {
+	return !e.denied[d]
+}
+
+// sendConst sends the metric of d, a descriptor outside of the metric tables,
+// if it passes the metric filter.
+func (e *Exporter) sendConst(ch chan<- prometheus.Metric, d *prometheus.Desc, t prometheus.ValueType, v float64, labelValues ...string) {
+	if e.allowed(d) {
+		ch <- prometheus.MustNewConstMetric(d, t, v, labelValues...)
+	}
+}
+
+// namedCollector is a collector of the metric named fqName.
+type namedCollector struct {
+	fqName string
+	prometheus.Collector
+}
+
+// allowedCollectors returns the collectors of cs whose metric passes the
+// metric filter.
+func (f *MetricFilter) allowedCollectors(cs ...namedCollector) []prometheus.Collector {
+	allowed := make([]prometheus.Collector, 0, len(cs))
+	for _, c := range cs {
+		if f.metricAllowed(c.fqName) {
+			allowed = append(allowed, c.Collector)
+		}
+	}
+	return allowed
+}
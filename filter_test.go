@@ -0,0 +1,221 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/model"
+)
+
+func TestMetricFilterAllowed(t *testing.T) {
+	f := &MetricFilter{
+		AllowStatsTypes: []string{"*-rtp", "transport"},
+		DenyStatsTypes:  []string{"outbound-*"},
+		DenyMetrics:     []string{"momo_*_qp_sum"},
+	}
+	for st, want := range map[string]bool{
+		"inbound-rtp":  true,
+		"outbound-rtp": false,
+		"transport":    true,
+		"data-channel": false,
+	} {
+		if got := f.statsTypeAllowed(st); got != want {
+			t.Errorf("statsTypeAllowed(%q) = %v; want %v", st, got, want)
+		}
+	}
+	for name, want := range map[string]bool{
+		"momo_inbound_rtp_qp_sum":               false,
+		"momo_inbound_rtp_bytes_received_total": true,
+	} {
+		if got := f.metricAllowed(name); got != want {
+			t.Errorf("metricAllowed(%q) = %v; want %v", name, got, want)
+		}
+	}
+
+	var none *MetricFilter
+	if !none.statsTypeAllowed("codec") || !none.metricAllowed("momo_up") {
+		t.Error("nil filter must allow everything")
+	}
+	if err := (&MetricFilter{DenyMetrics: []string{"["}}).Validate(); err == nil {
+		t.Error("expected error for invalid pattern")
+	}
+}
+
+func TestMetricFilter(t *testing.T) {
+	resp := `{
+		"version": "WebRTC Native Client Momo 2020.11 (db9d97e)",
+		"libwebrtc": "Shiguredo-Build M88.4324@{#2} (88.4324.2.0 54bd8488)",
+		"environment": "[aarch64] Ubuntu 18.04.5 LTS (nvidia-l4t-core 32.4.4-20201016123640)",
+		"stats": [
+			{
+				"bytesReceived": 1000,
+				"codecId": "RTCCodec_video_Inbound_96",
+				"framesDecoded": 300,
+				"id": "RTCInboundRTPVideoStream_1",
+				"kind": "video",
+				"packetsReceived": 10,
+				"qpSum": 4000,
+				"type": "inbound-rtp"
+			},
+			{
+				"bytesReceived": 1000,
+				"bytesSent": 2000,
+				"id": "RTCTransport_0_1",
+				"type": "transport"
+			}
+		]
+	}`
+	h := newMomo([]byte(resp))
+	defer h.Close()
+	e, err := NewExporter(TargetConfig{
+		URI:     h.URL,
+		Timeout: model.Duration(5 * time.Second),
+		ExportConfig: ExportConfig{
+			MetricFilter: &MetricFilter{
+				DenyStatsTypes: []string{"transport"},
+				AllowMetrics:   []string{"momo_inbound_rtp_*", "momo_up", "momo_build_*"},
+				DenyMetrics:    []string{"*_frame_*", "momo_inbound_rtp_qp_sum"},
+			},
+		},
+	}, log.NewNopLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	e.now = func() time.Time { return testTime }
+	expectMetrics(t, e, "metric_filter")
+}
+
+const metricFilterNamesResponse = `{
+	"version": "WebRTC Native Client Momo 2020.11 (db9d97e)",
+	"libwebrtc": "Shiguredo-Build M88.4324@{#2} (88.4324.2.0 54bd8488)",
+	"environment": "[aarch64] Ubuntu 18.04.5 LTS (nvidia-l4t-core 32.4.4-20201016123640)",
+	"stats": [
+		{
+			"id": "RTCCodec_video_Inbound_96",
+			"mimeType": "video/VP8",
+			"type": "codec"
+		},
+		{
+			"bytesReceived": 1000,
+			"codecId": "RTCCodec_video_Inbound_96",
+			"id": "RTCInboundRTPVideoStream_1",
+			"kind": "video",
+			"type": "inbound-rtp"
+		},
+		{
+			"active": true,
+			"bytesSent": 1000,
+			"framesSent": 10,
+			"id": "RTCOutboundRTPVideoStream_2",
+			"kind": "video",
+			"mediaSourceId": "RTCVideoSource_3",
+			"transportId": "RTCTransport_0_1",
+			"type": "outbound-rtp"
+		},
+		{
+			"bytesSent": 100,
+			"id": "RTCDataChannel_1",
+			"label": "serial",
+			"state": "open",
+			"type": "data-channel"
+		},
+		{
+			"dtlsCipher": "TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256",
+			"dtlsState": "connected",
+			"id": "RTCTransport_0_1",
+			"localCertificateId": "RTCCertificate_A",
+			"srtpCipher": "AES_CM_128_HMAC_SHA1_80",
+			"tlsVersion": "FEFD",
+			"type": "transport"
+		},
+		{
+			"fingerprint": "AA:BB",
+			"fingerprintAlgorithm": "sha-256",
+			"id": "RTCCertificate_A",
+			"type": "certificate"
+		}
+	]
+}`
+
+// gatherNames returns the names of the metric families collected from c.
+func gatherNames(t *testing.T, c prometheus.Collector) []string {
+	reg := prometheus.NewPedanticRegistry()
+	if err := reg.Register(c); err != nil {
+		t.Fatal(err)
+	}
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := make([]string, len(families))
+	for i, mf := range families {
+		names[i] = mf.GetName()
+	}
+	return names
+}
+
+func TestMetricFilterNames(t *testing.T) {
+	h := newMomo([]byte(metricFilterNamesResponse))
+	defer h.Close()
+	newExporter := func(filter *MetricFilter) *Exporter {
+		e, err := NewExporter(TargetConfig{
+			URI:     h.URL,
+			Timeout: model.Duration(5 * time.Second),
+			ExportConfig: ExportConfig{
+				TransportSecurityPolicy: &TransportSecurityPolicy{},
+				RTPAggregate:            &RTPAggregateConfig{},
+				GenericStats:            &GenericStatsConfig{},
+				MetricFilter:            filter,
+			},
+		}, log.NewNopLogger())
+		if err != nil {
+			t.Fatal(err)
+		}
+		e.process = newProcessCollector(t.TempDir(), func() int { return 0 }, log.NewNopLogger())
+		return e
+	}
+
+	// Allowing a single metric must export exactly that metric, which shows
+	// that the filter matches every metric by its name.
+	for _, name := range gatherNames(t, newExporter(nil)) {
+		got := gatherNames(t, newExporter(&MetricFilter{AllowMetrics: []string{name}}))
+		if !reflect.DeepEqual(got, []string{name}) {
+			t.Errorf("allowing only %s exports %v", name, got)
+		}
+	}
+	if got := gatherNames(t, newExporter(&MetricFilter{AllowMetrics: []string{"none"}})); len(got) != 0 {
+		t.Errorf("want no metrics, have %v", got)
+	}
+}
+
+func TestMetricFilterDescribe(t *testing.T) {
+	e, err := NewExporter(TargetConfig{
+		URI:     "http://localhost:8081/metrics",
+		Timeout: model.Duration(5 * time.Second),
+		ExportConfig: ExportConfig{
+			MetricFilter: &MetricFilter{DenyMetrics: []string{"momo_stats_*", "momo_exporter_*"}},
+		},
+	}, log.NewNopLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ch := make(chan *prometheus.Desc)
+	go func() {
+		e.Describe(ch)
+		close(ch)
+	}()
+	var n int
+	for d := range ch {
+		n++
+		if s := d.String(); strings.Contains(s, `fqName: "momo_stats_`) || strings.Contains(s, `fqName: "momo_exporter_`) {
+			t.Errorf("denied metric described: %s", s)
+		}
+	}
+	if n == 0 {
+		t.Error("want the allowed metrics to be described")
+	}
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"sort"
+
+	"github.com/go-kit/kit/log/level"
+	"github.com/iancoleman/strcase"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/model"
+)
+
+// GenericStatsConfig configures the export of the numeric stats fields that
+// are not in the metric tables.
+type GenericStatsConfig struct {
+	// PerField names the metrics momo_stats_<type>_<field> instead of
+	// exporting them all as momo_stats_value.
+	PerField bool `yaml:"per_field,omitempty"`
+}
+
+// genericStatsTypes are the stats types of libwebrtc, which stats_types may
+// name with generic stats even if they have no dedicated metrics.
+// https://www.w3.org/TR/webrtc-stats/#rtcstatstype-str*
+var genericStatsTypes = map[string]bool{
+	"codec":               true,
+	"inbound-rtp":         true,
+	"outbound-rtp":        true,
+	"remote-inbound-rtp":  true,
+	"remote-outbound-rtp": true,
+	"media-source":        true,
+	"media-playout":       true,
+	"csrc":                true,
+	"peer-connection":     true,
+	"data-channel":        true,
+	"transceiver":         true,
+	"sender":              true,
+	"receiver":            true,
+	"transport":           true,
+	"sctp-transport":      true,
+	"candidate-pair":      true,
+	"local-candidate":     true,
+	"remote-candidate":    true,
+	"certificate":         true,
+	"ice-server":          true,
+	"track":               true,
+	"stream":              true,
+}
+
+// genericSkipFields are numeric fields that identify rather than measure.
+var genericSkipFields = map[string]bool{
+	"timestamp":             true,
+	"ssrc":                  true,
+	"rtxSsrc":               true,
+	"fecSsrc":               true,
+	"dataChannelIdentifier": true,
+}
+
+var statsValue = newDesc("stats", "value",
+	"Value of a numeric stats field not covered by the other metrics. Counters and gauges are not told apart.",
+	[]string{"type", "field", "id"})
+
+// genericExporter exports the numeric stats fields not in the metric tables.
+type genericExporter struct {
+	config GenericStatsConfig
+	// descs caches the descriptors of the per-field metrics by name.
+	descs map[string]*prometheus.Desc
+}
+
+func newGenericExporter(config GenericStatsConfig) *genericExporter {
+	return &genericExporter{config: config, descs: map[string]*prometheus.Desc{}}
+}
+
+// genericFieldName returns the name of the per-field metric of field of
+// stats type t.
+func genericFieldName(t, field string) string {
+	return prometheus.BuildFQName(namespace, "stats", strcase.ToSnake(t)+"_"+strcase.ToSnake(field))
+}
+
+// exportGenericMetrics exports the numeric fields of stats m of type t that
+// are not exported by the metric table of t.
+func (e *Exporter) exportGenericMetrics(t string, m map[string]interface{}, ch chan<- prometheus.Metric) {
+	g := e.generic
+	if e.dropPerStreamRTP() && (t == "inbound-rtp" || t == "outbound-rtp") {
+		return
+	}
+	id, _ := m["id"].(string)
+	covered := statsTypeMetrics[t]
+	if t == "data-channel" {
+		covered = e.dataChannel.metrics
+	}
+
+	fields := make([]string, 0, len(m))
+	for field := range m {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	for _, field := range fields {
+		val, ok := m[field].(float64)
+		if !ok || genericSkipFields[field] {
+			continue
+		}
+		if _, ok := covered[field]; ok {
+			continue
+		}
+		if !g.config.PerField {
+			e.sendGenericMetric(ch, statsValue, val, t, field, id)
+			continue
+		}
+		name := genericFieldName(t, field)
+		desc, ok := g.descs[name]
+		if !ok {
+			if !model.IsValidMetricName(model.LabelValue(name)) {
+				level.Debug(e.logger).Log("msg", "Skipping stats field without a valid metric name", "type", t, "field", field)
+				continue
+			}
+			if !e.filter.metricAllowed(name) {
+				continue
+			}
+			desc = prometheus.NewDesc(name, "Value of the "+field+" field of "+t+" stats.", []string{"id"}, nil)
+			g.descs[name] = desc
+		}
+		e.sendGenericMetric(ch, desc, val, id)
+	}
+}
+
+// sendGenericMetric sends the generic metric desc. Field names come from the
+// stats, so the metric is skipped rather than panicking if it is invalid.
+func (e *Exporter) sendGenericMetric(ch chan<- prometheus.Metric, desc *prometheus.Desc, val float64, labelValues ...string) {
+	if !e.allowed(desc) {
+		return
+	}
+	m, err := prometheus.NewConstMetric(desc, prometheus.UntypedValue, val, labelValues...)
+	if err != nil {
+		level.Debug(e.logger).Log("msg", "Skipping invalid stats field", "err", err)
+		return
+	}
+	ch <- m
+}
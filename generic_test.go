@@ -0,0 +1,97 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/prometheus/common/model"
+)
+
+const genericStatsResponse = `{
+	"version": "WebRTC Native Client Momo 2020.11 (db9d97e)",
+	"libwebrtc": "Shiguredo-Build M88.4324@{#2} (88.4324.2.0 54bd8488)",
+	"environment": "[aarch64] Ubuntu 18.04.5 LTS (nvidia-l4t-core 32.4.4-20201016123640)",
+	"stats": [
+		{
+			"clockRate": 90000,
+			"id": "RTCCodec_video_Inbound_96",
+			"mimeType": "video/VP8",
+			"payloadType": 96,
+			"timestamp": 1605250000000000,
+			"type": "codec"
+		},
+		{
+			"bytesReceived": 1000,
+			"codecId": "RTCCodec_video_Inbound_96",
+			"id": "RTCInboundRTPVideoStream_1",
+			"kind": "video",
+			"powerEfficientDecoder": true,
+			"ssrc": 1234,
+			"timestamp": 1605250000000000,
+			"totalAssemblyTime": 0.25,
+			"type": "inbound-rtp"
+		}
+	]
+}`
+
+func newGenericTestExporter(t *testing.T, uri string, config GenericStatsConfig) *Exporter {
+	e, err := NewExporter(TargetConfig{
+		URI:     uri,
+		Timeout: model.Duration(5 * time.Second),
+		ExportConfig: ExportConfig{
+			StatsTypes:   []string{"codec", "inbound-rtp"},
+			GenericStats: &config,
+			MetricFilter: &MetricFilter{DenyMetrics: []string{"momo_stats_codec_payload_type"}},
+		},
+	}, log.NewNopLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	e.now = func() time.Time { return testTime }
+	return e
+}
+
+func TestGenericStats(t *testing.T) {
+	h := newMomo([]byte(genericStatsResponse))
+	defer h.Close()
+	expectMetrics(t, newGenericTestExporter(t, h.URL, GenericStatsConfig{}), "generic_stats")
+}
+
+func TestGenericStatsPerField(t *testing.T) {
+	h := newMomo([]byte(genericStatsResponse))
+	defer h.Close()
+	expectMetrics(t, newGenericTestExporter(t, h.URL, GenericStatsConfig{PerField: true}), "generic_stats_per_field")
+}
+
+func TestGenericStatsConfig(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "config.yml")
+	writeFile(t, filename, "targets:\n  - uri: http://localhost:8081/metrics\n    stats_types: [codec]\n    generic_stats:\n      per_field: true\n")
+	cfg, err := LoadConfig(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g := cfg.Targets[0].ExportConfig.GenericStats; g == nil || !g.PerField {
+		t.Errorf("want per-field generic stats, have %+v", g)
+	}
+}
+
+func TestGenericStatsInvalidFieldName(t *testing.T) {
+	resp := `{
+		"version": "WebRTC Native Client Momo 2020.11 (db9d97e)",
+		"libwebrtc": "Shiguredo-Build M88.4324@{#2} (88.4324.2.0 54bd8488)",
+		"environment": "[aarch64] Ubuntu 18.04.5 LTS (nvidia-l4t-core 32.4.4-20201016123640)",
+		"stats": [
+			{
+				"clockRate": 90000,
+				"id": "RTCCodec_video_Inbound_96",
+				"latencyµs": 40,
+				"type": "codec"
+			}
+		]
+	}`
+	h := newMomo([]byte(resp))
+	defer h.Close()
+	expectMetrics(t, newGenericTestExporter(t, h.URL, GenericStatsConfig{PerField: true}), "generic_stats_invalid_field")
+}
@@ -7,6 +7,10 @@ require (
 	github.com/iancoleman/strcase v0.1.2
 	github.com/koron/go-dproxy v1.3.0
 	github.com/prometheus/client_golang v1.9.0
+	github.com/prometheus/client_model v0.2.0
 	github.com/prometheus/common v0.15.0
+	github.com/prometheus/exporter-toolkit v0.5.1
+	github.com/prometheus/procfs v0.2.0
 	gopkg.in/alecthomas/kingpin.v2 v2.2.6
+	gopkg.in/yaml.v2 v2.4.0
 )
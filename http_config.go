@@ -0,0 +1,129 @@
+package main
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// HTTPClientConfig configures the requests sent to the WebRTC Native Client
+// Momo metrics API.
+type HTTPClientConfig struct {
+	BasicAuth       *BasicAuth        `yaml:"basic_auth,omitempty"`
+	BearerToken     string            `yaml:"bearer_token,omitempty"`
+	BearerTokenFile string            `yaml:"bearer_token_file,omitempty"`
+	Headers         map[string]string `yaml:"headers,omitempty"`
+	TLSConfig       TLSConfig         `yaml:"tls_config,omitempty"`
+	ProxyURL        string            `yaml:"proxy_url,omitempty"`
+}
+
+// BasicAuth holds the credentials used for HTTP basic authentication.
+type BasicAuth struct {
+	Username     string `yaml:"username"`
+	Password     string `yaml:"password,omitempty"`
+	PasswordFile string `yaml:"password_file,omitempty"`
+}
+
+// Validate checks that at most one authentication method is configured.
+func (c *HTTPClientConfig) Validate() error {
+	if c.BearerToken != "" && c.BearerTokenFile != "" {
+		return errors.New("at most one of bearer token and bearer token file must be configured")
+	}
+	if c.BasicAuth != nil && (c.BearerToken != "" || c.BearerTokenFile != "") {
+		return errors.New("at most one of basic auth and bearer token must be configured")
+	}
+	if c.BasicAuth != nil && c.BasicAuth.Password != "" && c.BasicAuth.PasswordFile != "" {
+		return errors.New("at most one of basic auth password and password file must be configured")
+	}
+	if _, err := c.proxy(); err != nil {
+		return err
+	}
+	return c.TLSConfig.Validate()
+}
+
+// proxy returns the proxy function for the configured proxy URL. Without a
+// proxy URL the proxy environment variables are honoured.
+func (c *HTTPClientConfig) proxy() (func(*http.Request) (*url.URL, error), error) {
+	if c.ProxyURL == "" {
+		return http.ProxyFromEnvironment, nil
+	}
+	u, err := url.Parse(c.ProxyURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid proxy URL %q: %w", c.ProxyURL, err)
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5":
+	default:
+		return nil, fmt.Errorf("unsupported proxy scheme: %q", u.Scheme)
+	}
+	return http.ProxyURL(u), nil
+}
+
+// newRoundTripper returns the http.RoundTripper used to scrape Momo.
+func newRoundTripper(c HTTPClientConfig) (http.RoundTripper, error) {
+	proxy, err := c.proxy()
+	if err != nil {
+		return nil, err
+	}
+	newRT := func(tlsConfig *tls.Config) http.RoundTripper {
+		return &http.Transport{
+			Proxy:           proxy,
+			TLSClientConfig: tlsConfig,
+		}
+	}
+
+	if c.TLSConfig.hasFiles() {
+		return newTLSRoundTripper(c.TLSConfig, newRT)
+	}
+	tlsConfig, err := newTLSConfig(&c.TLSConfig)
+	if err != nil {
+		return nil, err
+	}
+	return newRT(tlsConfig), nil
+}
+
+// prepareRequest adds the configured headers and credentials to req.
+// Password and token files are read on every call so that rotated
+// credentials are picked up without restarting the exporter.
+func (c *HTTPClientConfig) prepareRequest(req *http.Request) error {
+	for name, value := range c.Headers {
+		req.Header.Set(name, value)
+	}
+
+	if c.BasicAuth != nil {
+		password := c.BasicAuth.Password
+		if c.BasicAuth.PasswordFile != "" {
+			b, err := readSecretFile(c.BasicAuth.PasswordFile)
+			if err != nil {
+				return fmt.Errorf("unable to read basic auth password file %s: %w", c.BasicAuth.PasswordFile, err)
+			}
+			password = b
+		}
+		req.SetBasicAuth(c.BasicAuth.Username, password)
+	}
+
+	token := c.BearerToken
+	if c.BearerTokenFile != "" {
+		b, err := readSecretFile(c.BearerTokenFile)
+		if err != nil {
+			return fmt.Errorf("unable to read bearer token file %s: %w", c.BearerTokenFile, err)
+		}
+		token = b
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	return nil
+}
+
+func readSecretFile(filename string) (string, error) {
+	b, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(b)), nil
+}
@@ -1,18 +1,26 @@
 package main
 
 import (
+	"crypto/tls"
+	"crypto/x509"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"path"
+	"path/filepath"
 	"testing"
 	"time"
 
 	"github.com/go-kit/kit/log"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/exporter-toolkit/web"
 )
 
+var testTime = time.Unix(1608309190, 0)
+
 const (
 	testVersion     = "WebRTC Native Client Momo 20XX.Y (test)"
 	testEnvironment = "Test Environment"
@@ -52,13 +60,21 @@ func expectMetrics(t *testing.T, c prometheus.Collector, fixture string) {
 	}
 }
 
-func compare(t *testing.T, response string, fixture string) {
-	h := newMomo([]byte(response))
-	defer h.Close()
-	e, err := NewExporter(h.URL, true, 5*time.Second, log.NewNopLogger())
+// newTestExporter returns an Exporter whose clock is frozen at testTime so
+// that durations and timestamps in fixtures are stable.
+func newTestExporter(t *testing.T, uri string, timeout time.Duration) *Exporter {
+	e, err := NewExporter(TargetConfig{URI: uri, Timeout: model.Duration(timeout)}, log.NewNopLogger())
 	if err != nil {
 		t.Fatal(err)
 	}
+	e.now = func() time.Time { return testTime }
+	return e
+}
+
+func compare(t *testing.T, response string, fixture string) {
+	h := newMomo([]byte(response))
+	defer h.Close()
+	e := newTestExporter(t, h.URL, 5*time.Second)
 	expectMetrics(t, e, fixture)
 }
 
@@ -118,6 +134,37 @@ func TestInboundRTP(t *testing.T) {
 	compare(t, resp, "inbound_rtp")
 }
 
+func TestInboundRTPMediaType(t *testing.T) {
+	// Older libwebrtc builds report mediaType instead of kind.
+	resp := `{
+		"version": "WebRTC Native Client Momo 2020.1 (6f6f4ab)",
+		"libwebrtc": "Shiguredo-Build M79.3945@{#1} (79.3945.1.0 2dbff5b2)",
+		"environment": "[armv7l] Raspbian GNU/Linux 10 (buster)",
+		"stats": [
+			{
+				"bytesReceived": 481230,
+				"codecId": "RTCCodec_video_Inbound_96",
+				"firCount": 0,
+				"framesDecoded": 301,
+				"id": "RTCInboundRTPVideoStream_3012345678",
+				"isRemote": false,
+				"mediaType": "video",
+				"nackCount": 2,
+				"packetsLost": 0,
+				"packetsReceived": 540,
+				"pliCount": 1,
+				"qpSum": 9210,
+				"ssrc": 3012345678,
+				"timestamp": 1579850723174085,
+				"trackId": "RTCMediaStreamTrack_receiver_1",
+				"transportId": "RTCTransport_0_1",
+				"type": "inbound-rtp"
+			}
+		]
+	}`
+	compare(t, resp, "inbound_rtp_media_type")
+}
+
 func TestOutboundRTP(t *testing.T) {
 	resp := `{
 		"version": "WebRTC Native Client Momo 2020.11 (db9d97e)",
@@ -189,6 +236,90 @@ func TestDataChannel(t *testing.T) {
 	compare(t, resp, "data_channel")
 }
 
+func TestTrack(t *testing.T) {
+	resp := `{
+		"version": "WebRTC Native Client Momo 2020.11 (db9d97e)",
+		"libwebrtc": "Shiguredo-Build M88.4324@{#2} (88.4324.2.0 54bd8488)",
+		"environment": "[aarch64] Ubuntu 18.04.5 LTS (nvidia-l4t-core 32.4.4-20201016123640)",
+		"stats": [
+			{
+				"detached": false,
+				"ended": false,
+				"frameHeight": 720,
+				"frameWidth": 1280,
+				"framesDecoded": 2111,
+				"framesDropped": 3,
+				"framesReceived": 2112,
+				"freezeCount": 2,
+				"id": "RTCMediaStreamTrack_receiver_3",
+				"jitterBufferDelay": 84.217,
+				"jitterBufferEmittedCount": 2110,
+				"kind": "video",
+				"mediaSourceId": "",
+				"pauseCount": 0,
+				"remoteSource": true,
+				"timestamp": 1608309189926189,
+				"totalFreezesDuration": 1.372,
+				"totalPausesDuration": 0,
+				"trackIdentifier": "4c4f6b2e-9d1a-4f0e-8c43-2b7e1d2a6f11",
+				"type": "track"
+			},
+			{
+				"audioLevel": 0.0123,
+				"detached": false,
+				"ended": false,
+				"id": "RTCMediaStreamTrack_sender_1",
+				"kind": "audio",
+				"mediaSourceId": "RTCAudioSource_1",
+				"remoteSource": false,
+				"timestamp": 1608309189926189,
+				"totalAudioEnergy": 0.5712,
+				"totalSamplesDuration": 210.37,
+				"trackIdentifier": "audio",
+				"type": "track"
+			}
+		]
+	}`
+	compare(t, resp, "track")
+}
+
+func TestMediaPlayout(t *testing.T) {
+	resp := `{
+		"version": "WebRTC Native Client Momo 2023.1.0 (7a1cc1e8)",
+		"libwebrtc": "Shiguredo-Build M111.5563@{#4} (111.5563.4.0 4ad3f1a6)",
+		"environment": "[aarch64] Ubuntu 20.04.5 LTS (nvidia-l4t-core 35.1.0-20220825113828)",
+		"stats": [
+			{
+				"audioLevel": 0.0183,
+				"codecId": "CITu0A_111",
+				"concealedSamples": 1920,
+				"id": "IT01A3462178093",
+				"kind": "audio",
+				"packetsReceived": 5021,
+				"playoutId": "AP",
+				"ssrc": 3462178093,
+				"timestamp": 1676534400123456,
+				"totalSamplesReceived": 4819200,
+				"trackIdentifier": "b1f0c7e2-audio",
+				"transportId": "Tu0A",
+				"type": "inbound-rtp"
+			},
+			{
+				"id": "AP",
+				"kind": "audio",
+				"synthesizedSamplesDuration": 0.04,
+				"synthesizedSamplesEvents": 2,
+				"timestamp": 1676534400123456,
+				"totalPlayoutDelay": 4813.44,
+				"totalSamplesCount": 4819200,
+				"totalSamplesDuration": 100.4,
+				"type": "media-playout"
+			}
+		]
+	}`
+	compare(t, resp, "media_playout")
+}
+
 func TestPeerConnection(t *testing.T) {
 	resp := `{
 		"version": "WebRTC Native Client Momo 2020.11 (db9d97e)",
@@ -245,11 +376,7 @@ func TestDeadline(t *testing.T) {
 		h.Close()
 	}()
 
-	e, err := NewExporter(h.URL, true, 1*time.Second, log.NewNopLogger())
-	if err != nil {
-		t.Fatal(err)
-	}
-
+	e := newTestExporter(t, h.URL, 1*time.Second)
 	expectMetrics(t, e, "deadline")
 }
 
@@ -257,10 +384,90 @@ func TestNotFound(t *testing.T) {
 	h := httptest.NewServer(http.NotFoundHandler())
 	defer h.Close()
 
-	e, err := NewExporter(h.URL, true, 5*time.Second, log.NewNopLogger())
+	e := newTestExporter(t, h.URL, 5*time.Second)
+	expectMetrics(t, e, "not_found")
+}
+
+func TestConnectionRefused(t *testing.T) {
+	h := httptest.NewServer(http.NotFoundHandler())
+	h.Close()
+
+	e := newTestExporter(t, h.URL, 5*time.Second)
+	expectMetrics(t, e, "connection_refused")
+}
+
+func TestInvalidStats(t *testing.T) {
+	resp := `{
+		"version": "WebRTC Native Client Momo 2020.11 (db9d97e)",
+		"libwebrtc": "Shiguredo-Build M88.4324@{#2} (88.4324.2.0 54bd8488)",
+		"environment": "[aarch64] Ubuntu 18.04.5 LTS (nvidia-l4t-core 32.4.4-20201016123640)",
+		"stats": {}
+	}`
+	compare(t, resp, "invalid_stats")
+}
+
+func TestWebConfig(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCA(t, "exporter CA")
+	certPEM, keyPEM := ca.issue(t, "exporter.local", x509.ExtKeyUsageServerAuth)
+	writeFile(t, filepath.Join(dir, "server.pem"), string(certPEM))
+	writeFile(t, filepath.Join(dir, "server.key"), string(keyPEM))
+	webConfig := filepath.Join(dir, "web.yml")
+	// The password of dave is dave123.
+	writeFile(t, webConfig, `tls_server_config:
+  cert_file: server.pem
+  key_file: server.key
+basic_auth_users:
+  dave: $2y$10$2UXri9cIDdgeKjBo4Rlpx.U3ZLDV8X1IxKmsfOvhcM5oXQt/mLmXq
+`)
+	configFile := filepath.Join(dir, "config.yml")
+	writeFile(t, configFile, "targets: []\n")
+
+	targets := newTargetManager(log.NewNopLogger())
+	reloader := newReloader(configFile, targets, log.NewNopLogger())
+	mux := newServeMux("/metrics", targets, reloader, log.NewNopLogger())
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
 	if err != nil {
 		t.Fatal(err)
 	}
+	srv := &http.Server{Handler: mux}
+	go web.Serve(l, srv, webConfig, log.NewNopLogger())
+	defer srv.Close()
 
-	expectMetrics(t, e, "not_found")
+	pool := x509.NewCertPool()
+	pool.AppendCertsFromPEM(ca.pem)
+	client := &http.Client{Transport: &http.Transport{
+		TLSClientConfig: &tls.Config{RootCAs: pool, ServerName: "exporter.local"},
+	}}
+
+	for _, tt := range []struct {
+		method, path string
+	}{
+		{http.MethodGet, "/metrics"},
+		{http.MethodGet, "/-/healthy"},
+		{http.MethodGet, "/-/ready"},
+		{http.MethodPost, "/-/reload"},
+		{http.MethodGet, "/"},
+	} {
+		for _, auth := range []bool{false, true} {
+			req, err := http.NewRequest(tt.method, "https://"+l.Addr().String()+tt.path, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := http.StatusUnauthorized
+			if auth {
+				req.SetBasicAuth("dave", "dave123")
+				want = http.StatusOK
+			}
+			resp, err := client.Do(req)
+			if err != nil {
+				t.Fatalf("%s %s: %v", tt.method, tt.path, err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != want {
+				t.Errorf("%s %s with credentials %v: want status %d, have %d", tt.method, tt.path, auth, want, resp.StatusCode)
+			}
+		}
+	}
 }
@@ -0,0 +1,237 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/model"
+)
+
+// postprocessor applies the relabel rules to the metrics of an exporter. The
+// rules need the names of the metrics, which are only known from the gathered
+// metric families, so the exporter is collected through a private registry.
+type postprocessor struct {
+	rules    []*relabelRule
+	registry *prometheus.Registry
+	logger   log.Logger
+	// dropped counts the series dropped because they collided with another
+	// series after relabeling. It is exported as is rather than relabeled,
+	// and it is nil if the metric filter denies it.
+	dropped prometheus.Counter
+	// descs caches the descriptors of the exported metrics.
+	descs map[string]*prometheus.Desc
+}
+
+// collectorFunc is an unchecked collector collecting with the function.
+type collectorFunc func(ch chan<- prometheus.Metric)
+
+func (f collectorFunc) Describe(ch chan<- *prometheus.Desc) {}
+
+func (f collectorFunc) Collect(ch chan<- prometheus.Metric) { f(ch) }
+
+const relabelDroppedSeriesName = "momo_exporter_relabel_dropped_series_total"
+
+func newPostprocessor(collect func(ch chan<- prometheus.Metric), rules []*relabelRule, filter *MetricFilter, logger log.Logger) *postprocessor {
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(collectorFunc(collect))
+	p := &postprocessor{
+		rules:    rules,
+		registry: registry,
+		logger:   logger,
+		descs:    map[string]*prometheus.Desc{},
+	}
+	if filter.metricAllowed(relabelDroppedSeriesName) {
+		p.dropped = prometheus.NewCounter(prometheus.CounterOpts{
+			Name: relabelDroppedSeriesName,
+			Help: "Number of series dropped because relabeling made them collide with a series they cannot be merged with.",
+		})
+	}
+	return p
+}
+
+// series is a series after relabeling, with the values of the series merged
+// into it.
+type series struct {
+	name   string
+	help   string
+	t      dto.MetricType
+	labels map[string]string
+	metric *dto.Metric
+}
+
+func (p *postprocessor) collect(ch chan<- prometheus.Metric) {
+	families, err := p.registry.Gather()
+	if err != nil {
+		level.Error(p.logger).Log("msg", "Error gathering metrics", "err", err)
+	}
+
+	var (
+		all     []*series
+		byKey   = map[string]*series{}
+		types   = map[string]dto.MetricType{}
+		merged  int
+		dropped []string
+	)
+	for _, mf := range families {
+		for _, m := range mf.Metric {
+			name := mf.GetName()
+			labels := make(map[string]string, len(m.Label)+1)
+			for _, lp := range m.Label {
+				labels[lp.GetName()] = lp.GetValue()
+			}
+			labels[model.MetricNameLabel] = name
+			if !relabel(p.rules, labels) {
+				continue
+			}
+			name = labels[model.MetricNameLabel]
+			delete(labels, model.MetricNameLabel)
+			if t, ok := types[name]; ok && t != mf.GetType() {
+				// Relabeling renamed a metric to the name of a metric of
+				// another type.
+				dropped = append(dropped, name)
+				continue
+			}
+			types[name] = mf.GetType()
+
+			key := seriesKey(name, labels)
+			if s, ok := byKey[key]; ok {
+				// Series that relabeling made identical are merged if they
+				// count events, e.g. the bytes of the streams of a kind once
+				// their ids are dropped. The sum of gauges, such as the frame
+				// sizes of the streams or info metrics, means nothing, so the
+				// first series is kept.
+				if !mergeable(s.t) {
+					dropped = append(dropped, name)
+					continue
+				}
+				s.metric = mergeMetrics(s.t, s.metric, m)
+				merged++
+				continue
+			}
+			s := &series{name: name, help: mf.GetHelp(), t: mf.GetType(), labels: labels, metric: m}
+			byKey[key] = s
+			all = append(all, s)
+		}
+	}
+	if merged > 0 {
+		level.Debug(p.logger).Log("msg", "Merged series that collided after relabeling", "merged", merged)
+	}
+	if len(dropped) > 0 {
+		level.Warn(p.logger).Log("msg", "Dropped series that collided after relabeling", "dropped", len(dropped), "metrics", strings.Join(uniqueSorted(dropped), ","))
+	}
+	if p.dropped != nil {
+		p.dropped.Add(float64(len(dropped)))
+		ch <- p.dropped
+	}
+
+	for _, s := range all {
+		metric, err := p.constMetric(s.name, s.help, s.t, s.labels, s.metric)
+		if err != nil {
+			level.Error(p.logger).Log("msg", "Can't export metric", "metric", s.name, "err", err)
+			continue
+		}
+		ch <- metric
+	}
+}
+
+// seriesKey identifies the series named name with labels.
+func seriesKey(name string, labels map[string]string) string {
+	pairs := make([]string, 0, len(labels)+1)
+	for n, v := range labels {
+		pairs = append(pairs, n+"\xfe"+v)
+	}
+	sort.Strings(pairs)
+	return name + "\xff" + strings.Join(pairs, "\xff")
+}
+
+// uniqueSorted returns the distinct strings of ss, sorted.
+func uniqueSorted(ss []string) []string {
+	sort.Strings(ss)
+	unique := ss[:0]
+	for i, s := range ss {
+		if i == 0 || s != ss[i-1] {
+			unique = append(unique, s)
+		}
+	}
+	return unique
+}
+
+// mergeable reports whether series of type t can be merged by summing them.
+// Only counters and histograms can: gauges, untyped metrics such as the
+// generic stats, and the quantiles of summaries cannot be summed.
+func mergeable(t dto.MetricType) bool {
+	return t == dto.MetricType_COUNTER || t == dto.MetricType_HISTOGRAM
+}
+
+// mergeMetrics returns the sum of a and b, of type t, which is mergeable.
+func mergeMetrics(t dto.MetricType, a, b *dto.Metric) *dto.Metric {
+	if t == dto.MetricType_COUNTER {
+		v := a.GetCounter().GetValue() + b.GetCounter().GetValue()
+		return &dto.Metric{Counter: &dto.Counter{Value: &v}}
+	}
+	count := a.GetHistogram().GetSampleCount() + b.GetHistogram().GetSampleCount()
+	sum := a.GetHistogram().GetSampleSum() + b.GetHistogram().GetSampleSum()
+	counts := map[float64]uint64{}
+	for _, h := range []*dto.Histogram{a.GetHistogram(), b.GetHistogram()} {
+		for _, bucket := range h.Bucket {
+			counts[bucket.GetUpperBound()] += bucket.GetCumulativeCount()
+		}
+	}
+	h := &dto.Histogram{SampleCount: &count, SampleSum: &sum}
+	for bound, n := range counts {
+		bound, n := bound, n
+		h.Bucket = append(h.Bucket, &dto.Bucket{UpperBound: &bound, CumulativeCount: &n})
+	}
+	return &dto.Metric{Histogram: h}
+}
+
+// constMetric returns a metric named name with the labels and the value of m.
+func (p *postprocessor) constMetric(name, help string, t dto.MetricType, labels map[string]string, m *dto.Metric) (prometheus.Metric, error) {
+	if !model.IsValidMetricName(model.LabelValue(name)) {
+		return nil, fmt.Errorf("invalid metric name %q", name)
+	}
+	names := make([]string, 0, len(labels))
+	for n := range labels {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	values := make([]string, len(names))
+	for i, n := range names {
+		values[i] = labels[n]
+	}
+
+	key := name + "\xff" + help + "\xff" + strings.Join(names, "\xff")
+	desc, ok := p.descs[key]
+	if !ok {
+		desc = prometheus.NewDesc(name, help, names, nil)
+		p.descs[key] = desc
+	}
+
+	switch t {
+	case dto.MetricType_COUNTER:
+		return prometheus.NewConstMetric(desc, prometheus.CounterValue, m.GetCounter().GetValue(), values...)
+	case dto.MetricType_GAUGE:
+		return prometheus.NewConstMetric(desc, prometheus.GaugeValue, m.GetGauge().GetValue(), values...)
+	case dto.MetricType_HISTOGRAM:
+		h := m.GetHistogram()
+		buckets := make(map[float64]uint64, len(h.Bucket))
+		for _, b := range h.Bucket {
+			buckets[b.GetUpperBound()] = b.GetCumulativeCount()
+		}
+		return prometheus.NewConstHistogram(desc, h.GetSampleCount(), h.GetSampleSum(), buckets, values...)
+	case dto.MetricType_SUMMARY:
+		s := m.GetSummary()
+		quantiles := make(map[float64]float64, len(s.Quantile))
+		for _, q := range s.Quantile {
+			quantiles[q.GetQuantile()] = q.GetValue()
+		}
+		return prometheus.NewConstSummary(desc, s.GetSampleCount(), s.GetSampleSum(), quantiles, values...)
+	default:
+		return prometheus.NewConstMetric(desc, prometheus.UntypedValue, m.GetUntyped().GetValue(), values...)
+	}
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"net"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/procfs"
+)
+
+// Modes of WebRTC Native Client Momo, given as its subcommand.
+var momoModes = map[string]bool{"test": true, "ayame": true, "sora": true}
+
+// ProcSDConfig configures discovery of WebRTC Native Client Momo processes
+// running on the local host. Every process started with --metrics-port
+// becomes a target; the settings other than RefreshInterval, Binaries,
+// ProcFS and Host apply to every discovered target.
+type ProcSDConfig struct {
+	RefreshInterval model.Duration `yaml:"refresh_interval,omitempty"`
+	Binaries        []string       `yaml:"binaries,omitempty"`
+	ProcFS          string         `yaml:"procfs,omitempty"`
+	Host            string         `yaml:"host,omitempty"`
+	Timeout         model.Duration `yaml:"timeout,omitempty"`
+
+	ExportConfig     ExportConfig     `yaml:",inline"`
+	HTTPClientConfig HTTPClientConfig `yaml:",inline"`
+}
+
+// Validate fills in defaults and checks the configuration for errors.
+func (c *ProcSDConfig) Validate() error {
+	if c.RefreshInterval == 0 {
+		c.RefreshInterval = defaultRefreshInterval
+	}
+	if len(c.Binaries) == 0 {
+		c.Binaries = []string{"momo"}
+	}
+	if c.ProcFS == "" {
+		c.ProcFS = procfs.DefaultMountPoint
+	}
+	if c.Host == "" {
+		c.Host = "127.0.0.1"
+	}
+	// Validate the settings shared by all targets once, on a placeholder.
+	t := c.target(momoProcess{metricsPort: 8081})
+	return t.Validate()
+}
+
+// target returns the configuration of the target scraping process p.
+func (c *ProcSDConfig) target(p momoProcess) TargetConfig {
+	address := net.JoinHostPort(c.Host, strconv.Itoa(p.metricsPort))
+	return TargetConfig{
+		Name:    address,
+		URI:     "http://" + address + "/metrics",
+		Timeout: c.Timeout,
+		Labels: map[string]string{
+			"pid":        strconv.Itoa(p.pid),
+			"mode":       p.mode,
+			"channel_id": p.channelID,
+		},
+		ExportConfig:     c.ExportConfig,
+		HTTPClientConfig: c.HTTPClientConfig,
+		pid:              p.pid,
+		procFS:           c.ProcFS,
+	}
+}
+
+// momoProcess holds the settings parsed from the command line of a running
+// WebRTC Native Client Momo.
+type momoProcess struct {
+	pid         int
+	metricsPort int
+	mode        string
+	channelID   string
+}
+
+// parseMomoCmdline parses the command line of a Momo process. It returns
+// false if the process does not serve the metrics API.
+//
+// Both the current syntax, with --signaling-url and --channel-id or
+// --room-id, and the older positional syntax, e.g.
+// "momo sora wss://example.com/signaling channel", are understood.
+func parseMomoCmdline(args []string) (momoProcess, bool) {
+	var p momoProcess
+	p.metricsPort = -1
+
+	var positional []string
+	for i := 1; i < len(args); i++ {
+		arg := args[i]
+		if !strings.HasPrefix(arg, "-") {
+			if p.mode == "" && momoModes[arg] {
+				p.mode = arg
+			} else if p.mode != "" {
+				positional = append(positional, arg)
+			}
+			continue
+		}
+
+		name, value := arg, ""
+		hasValue := false
+		if j := strings.Index(arg, "="); j >= 0 {
+			name, value, hasValue = arg[:j], arg[j+1:], true
+		}
+		switch name {
+		case "--metrics-port", "--channel-id", "--room-id":
+			if !hasValue {
+				if i+1 >= len(args) {
+					continue
+				}
+				i++
+				value = args[i]
+			}
+			if name == "--metrics-port" {
+				if port, err := strconv.Atoi(value); err == nil {
+					p.metricsPort = port
+				}
+			} else {
+				p.channelID = value
+			}
+		}
+	}
+
+	if p.channelID == "" {
+		for i, arg := range positional {
+			if strings.Contains(arg, "://") && i+1 < len(positional) {
+				p.channelID = positional[i+1]
+				break
+			}
+		}
+	}
+
+	return p, p.metricsPort > 0
+}
+
+// procDiscoverer lists the Momo processes running on the local host.
+type procDiscoverer struct {
+	config ProcSDConfig
+	logger log.Logger
+}
+
+func newProcDiscoverer(config ProcSDConfig, logger log.Logger) *procDiscoverer {
+	return &procDiscoverer{config: config, logger: logger}
+}
+
+// discover returns a target for every Momo process serving the metrics API.
+func (d *procDiscoverer) discover() []TargetConfig {
+	procs, err := d.processes()
+	if err != nil {
+		level.Error(d.logger).Log("msg", "Error listing processes", "err", err)
+		return nil
+	}
+
+	var targets []TargetConfig
+	for _, p := range procs {
+		t := d.config.target(p)
+		if err := t.Validate(); err != nil {
+			level.Warn(d.logger).Log("msg", "Ignoring invalid target", "pid", p.pid, "err", err)
+			continue
+		}
+		targets = append(targets, t)
+	}
+	return targets
+}
+
+func (d *procDiscoverer) processes() ([]momoProcess, error) {
+	fs, err := procfs.NewFS(d.config.ProcFS)
+	if err != nil {
+		return nil, err
+	}
+	all, err := fs.AllProcs()
+	if err != nil {
+		return nil, err
+	}
+	sort.Sort(all)
+
+	var procs []momoProcess
+	ports := map[int]bool{}
+	for _, proc := range all {
+		// Processes may exit while they are listed.
+		args, err := proc.CmdLine()
+		if err != nil || len(args) == 0 || !d.isMomo(args[0]) {
+			continue
+		}
+		p, ok := parseMomoCmdline(args)
+		if !ok || ports[p.metricsPort] {
+			continue
+		}
+		p.pid = proc.PID
+		ports[p.metricsPort] = true
+		procs = append(procs, p)
+	}
+	return procs, nil
+}
+
+func (d *procDiscoverer) isMomo(executable string) bool {
+	base := filepath.Base(executable)
+	for _, b := range d.config.Binaries {
+		if base == b {
+			return true
+		}
+	}
+	return false
+}
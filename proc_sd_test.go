@@ -0,0 +1,107 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/go-kit/kit/log"
+)
+
+func TestParseMomoCmdline(t *testing.T) {
+	for _, tc := range []struct {
+		cmdline string
+		want    momoProcess
+		ok      bool
+	}{
+		{
+			cmdline: "./momo --metrics-port 8081 test",
+			want:    momoProcess{metricsPort: 8081, mode: "test"},
+			ok:      true,
+		},
+		{
+			cmdline: "/usr/local/bin/momo --no-audio-device --metrics-port=8082 sora --auto --video-codec-type VP8 wss://sora.example.com/signaling robot-1",
+			want:    momoProcess{metricsPort: 8082, mode: "sora", channelID: "robot-1"},
+			ok:      true,
+		},
+		{
+			cmdline: "momo --metrics-port 8083 sora --signaling-url wss://sora.example.com/signaling --channel-id robot-2 --role sendonly",
+			want:    momoProcess{metricsPort: 8083, mode: "sora", channelID: "robot-2"},
+			ok:      true,
+		},
+		{
+			cmdline: "momo --metrics-port 8084 ayame wss://ayame-labo.shiguredo.jp/signaling robot-room",
+			want:    momoProcess{metricsPort: 8084, mode: "ayame", channelID: "robot-room"},
+			ok:      true,
+		},
+		{
+			cmdline: "momo ayame --signaling-url wss://ayame-labo.shiguredo.jp/signaling --room-id=robot-room --metrics-port 8085",
+			want:    momoProcess{metricsPort: 8085, mode: "ayame", channelID: "robot-room"},
+			ok:      true,
+		},
+		{
+			cmdline: "momo test",
+			ok:      false,
+		},
+		{
+			cmdline: "momo --metrics-port -1 test",
+			ok:      false,
+		},
+	} {
+		have, ok := parseMomoCmdline(strings.Fields(tc.cmdline))
+		if ok != tc.ok {
+			t.Errorf("%q: want ok %v, have %v", tc.cmdline, tc.ok, ok)
+			continue
+		}
+		if ok && !reflect.DeepEqual(have, tc.want) {
+			t.Errorf("%q: want %+v, have %+v", tc.cmdline, tc.want, have)
+		}
+	}
+}
+
+// writeProc creates a fake /proc/<pid>/cmdline below dir.
+func writeProc(t *testing.T, dir string, pid string, args ...string) {
+	if err := os.MkdirAll(filepath.Join(dir, pid), 0755); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, filepath.Join(dir, pid, "cmdline"), strings.Join(args, "\x00")+"\x00")
+}
+
+func TestProcDiscover(t *testing.T) {
+	dir := t.TempDir()
+	writeProc(t, dir, "200", "/opt/momo/momo", "--metrics-port", "8082", "sora", "--signaling-url", "wss://sora.example.com/signaling", "--channel-id", "robot-1")
+	writeProc(t, dir, "100", "./momo", "--metrics-port", "8081", "test")
+	writeProc(t, dir, "300", "./momo", "test")
+	writeProc(t, dir, "400", "/usr/bin/python3", "--metrics-port", "8083")
+	writeProc(t, dir, "self", "./momo", "--metrics-port", "8084", "test")
+
+	c := ProcSDConfig{ProcFS: dir}
+	if err := c.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	d := newProcDiscoverer(c, log.NewNopLogger())
+
+	want := []TargetConfig{
+		{
+			Name:    "127.0.0.1:8081",
+			URI:     "http://127.0.0.1:8081/metrics",
+			Timeout: defaultTimeout,
+			Labels:  map[string]string{"pid": "100", "mode": "test", "channel_id": ""},
+			pid:     100,
+			procFS:  dir,
+		},
+		{
+			Name:    "127.0.0.1:8082",
+			URI:     "http://127.0.0.1:8082/metrics",
+			Timeout: defaultTimeout,
+			Labels:  map[string]string{"pid": "200", "mode": "sora", "channel_id": "robot-1"},
+			pid:     200,
+			procFS:  dir,
+		},
+	}
+	if have := d.discover(); !reflect.DeepEqual(have, want) {
+		t.Errorf("want targets %+v, have %+v", want, have)
+	}
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/procfs"
+)
+
+var (
+	processCPUSeconds = newDesc("process", "cpu_seconds_total",
+		"Total user and system CPU time spent by the WebRTC Native Client Momo process in seconds.", nil)
+	processResidentMemory = newDesc("process", "resident_memory_bytes",
+		"Resident memory size of the WebRTC Native Client Momo process in bytes.", nil)
+	processVirtualMemory = newDesc("process", "virtual_memory_bytes",
+		"Virtual memory size of the WebRTC Native Client Momo process in bytes.", nil)
+	processThreads = newDesc("process", "threads",
+		"Number of threads of the WebRTC Native Client Momo process.", nil)
+	processOpenFDs = newDesc("process", "open_fds",
+		"Number of open file descriptors of the WebRTC Native Client Momo process.", nil)
+	processContextSwitches = newDesc("process", "context_switches_total",
+		"Total number of context switches of the WebRTC Native Client Momo process.", []string{"type"})
+	processReadBytes = newDesc("process", "read_bytes_total",
+		"Total number of bytes the WebRTC Native Client Momo process read from storage.", nil)
+	processWriteBytes = newDesc("process", "write_bytes_total",
+		"Total number of bytes the WebRTC Native Client Momo process wrote to storage.", nil)
+)
+
+// processDescs are the descriptors of the process metrics.
+var processDescs = []*prometheus.Desc{
+	processCPUSeconds, processResidentMemory, processVirtualMemory, processThreads,
+	processOpenFDs, processContextSwitches, processReadBytes, processWriteBytes,
+}
+
+// processCollector exports the resource usage of a Momo process, read from
+// /proc/<pid>/stat, status, io and fd.
+type processCollector struct {
+	procFS string
+	pid    func() int
+	logger log.Logger
+}
+
+func newProcessCollector(procFS string, pid func() int, logger log.Logger) *processCollector {
+	return &processCollector{procFS: procFS, pid: pid, logger: logger}
+}
+
+// Describe implements prometheus.Collector.
+func (c *processCollector) Describe(ch chan<- *prometheus.Desc) {
+	for _, d := range processDescs {
+		ch <- d
+	}
+}
+
+// Collect implements prometheus.Collector. Nothing is exported while the
+// process is not running.
+func (c *processCollector) Collect(ch chan<- prometheus.Metric) {
+	c.collect(ch, func(*prometheus.Desc) bool { return true })
+}
+
+// collect exports the process metrics whose descriptor allowed accepts.
+func (c *processCollector) collect(ch chan<- prometheus.Metric, allowed func(*prometheus.Desc) bool) {
+	send := func(d *prometheus.Desc, t prometheus.ValueType, v float64, labelValues ...string) {
+		if allowed(d) {
+			ch <- prometheus.MustNewConstMetric(d, t, v, labelValues...)
+		}
+	}
+	pid := c.pid()
+	if pid == 0 {
+		return
+	}
+
+	fs, err := procfs.NewFS(c.procFS)
+	if err != nil {
+		level.Error(c.logger).Log("msg", "Can't open procfs", "err", err)
+		return
+	}
+	p, err := fs.Proc(pid)
+	if err != nil {
+		level.Debug(c.logger).Log("msg", "Momo process not found", "pid", pid, "err", err)
+		return
+	}
+
+	if stat, err := p.Stat(); err == nil {
+		send(processCPUSeconds, prometheus.CounterValue, stat.CPUTime())
+		send(processResidentMemory, prometheus.GaugeValue, float64(stat.ResidentMemory()))
+		send(processVirtualMemory, prometheus.GaugeValue, float64(stat.VirtualMemory()))
+		send(processThreads, prometheus.GaugeValue, float64(stat.NumThreads))
+	} else {
+		level.Debug(c.logger).Log("msg", "Can't read process stat", "pid", pid, "err", err)
+	}
+
+	if status, err := p.NewStatus(); err == nil {
+		send(processContextSwitches, prometheus.CounterValue, float64(status.VoluntaryCtxtSwitches), "voluntary")
+		send(processContextSwitches, prometheus.CounterValue, float64(status.NonVoluntaryCtxtSwitches), "nonvoluntary")
+	} else {
+		level.Debug(c.logger).Log("msg", "Can't read process status", "pid", pid, "err", err)
+	}
+
+	// /proc/<pid>/io is only readable by the owner of the process.
+	if io, err := p.IO(); err == nil {
+		send(processReadBytes, prometheus.CounterValue, float64(io.ReadBytes))
+		send(processWriteBytes, prometheus.CounterValue, float64(io.WriteBytes))
+	} else {
+		level.Debug(c.logger).Log("msg", "Can't read process io", "pid", pid, "err", err)
+	}
+
+	if fds, err := p.FileDescriptorsLen(); err == nil {
+		send(processOpenFDs, prometheus.GaugeValue, float64(fds))
+	} else {
+		level.Debug(c.logger).Log("msg", "Can't read process fds", "pid", pid, "err", err)
+	}
+}
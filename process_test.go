@@ -0,0 +1,67 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/go-kit/kit/log"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestProcessCollector(t *testing.T) {
+	dir := t.TempDir()
+	writeProc(t, dir, "100", "./momo", "--metrics-port", "8081", "test")
+	writeFile(t, filepath.Join(dir, "100", "stat"),
+		"100 (momo) S 1 100 100 0 -1 4194560 32533 0 26 0 1677 44 0 0 20 0 12 0 82375 56274944 1981 18446744073709551615 1 1 0 0 0 0 0 4096 0 0 0 0 17 0 0 0 0 0 0 0 0 0 0 0 0 0 0\n")
+	writeFile(t, filepath.Join(dir, "100", "status"),
+		"Name:\tmomo\nState:\tS (sleeping)\nvoluntary_ctxt_switches:\t4742\nnonvoluntary_ctxt_switches:\t1727\n")
+	writeFile(t, filepath.Join(dir, "100", "io"),
+		"rchar: 750339\nwchar: 818609\nsyscr: 7405\nsyscw: 5245\nread_bytes: 1024\nwrite_bytes: 2048\ncancelled_write_bytes: 0\n")
+	for _, fd := range []string{"0", "1", "2", "3"} {
+		if err := os.MkdirAll(filepath.Join(dir, "100", "fd", fd), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	c := newProcessCollector(dir, func() int { return 100 }, log.NewNopLogger())
+	expected := fmt.Sprintf(`
+# HELP momo_process_context_switches_total Total number of context switches of the WebRTC Native Client Momo process.
+# TYPE momo_process_context_switches_total counter
+momo_process_context_switches_total{type="nonvoluntary"} 1727
+momo_process_context_switches_total{type="voluntary"} 4742
+# HELP momo_process_cpu_seconds_total Total user and system CPU time spent by the WebRTC Native Client Momo process in seconds.
+# TYPE momo_process_cpu_seconds_total counter
+momo_process_cpu_seconds_total 17.21
+# HELP momo_process_open_fds Number of open file descriptors of the WebRTC Native Client Momo process.
+# TYPE momo_process_open_fds gauge
+momo_process_open_fds 4
+# HELP momo_process_read_bytes_total Total number of bytes the WebRTC Native Client Momo process read from storage.
+# TYPE momo_process_read_bytes_total counter
+momo_process_read_bytes_total 1024
+# HELP momo_process_resident_memory_bytes Resident memory size of the WebRTC Native Client Momo process in bytes.
+# TYPE momo_process_resident_memory_bytes gauge
+momo_process_resident_memory_bytes %d
+# HELP momo_process_threads Number of threads of the WebRTC Native Client Momo process.
+# TYPE momo_process_threads gauge
+momo_process_threads 12
+# HELP momo_process_virtual_memory_bytes Virtual memory size of the WebRTC Native Client Momo process in bytes.
+# TYPE momo_process_virtual_memory_bytes gauge
+momo_process_virtual_memory_bytes 5.6274944e+07
+# HELP momo_process_write_bytes_total Total number of bytes the WebRTC Native Client Momo process wrote to storage.
+# TYPE momo_process_write_bytes_total counter
+momo_process_write_bytes_total 2048
+`, 1981*os.Getpagesize())
+	if err := testutil.CollectAndCompare(c, strings.NewReader(expected)); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestProcessCollectorExitedProcess(t *testing.T) {
+	c := newProcessCollector(t.TempDir(), func() int { return 100 }, log.NewNopLogger())
+	if n := testutil.CollectAndCount(c); n != 0 {
+		t.Errorf("want no metrics for an exited process, have %d", n)
+	}
+}
@@ -0,0 +1,266 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/prometheus/common/model"
+)
+
+// Relabel actions. They follow the Prometheus metric_relabel_configs, plus
+// hash, which replaces a value with a stable hash of it, keyed with a secret
+// if one is configured.
+const (
+	relabelReplace   = "replace"
+	relabelKeep      = "keep"
+	relabelDrop      = "drop"
+	relabelLabelDrop = "labeldrop"
+	relabelLabelMap  = "labelmap"
+	relabelHashMod   = "hashmod"
+	relabelHash      = "hash"
+)
+
+// RelabelConfig is a rule rewriting the labels of the exported metrics. The
+// metric name is available as the __name__ label.
+type RelabelConfig struct {
+	SourceLabels []string `yaml:"source_labels,flow,omitempty"`
+	Separator    *string  `yaml:"separator,omitempty"`
+	Regex        string   `yaml:"regex,omitempty"`
+	Modulus      uint64   `yaml:"modulus,omitempty"`
+	TargetLabel  string   `yaml:"target_label,omitempty"`
+	Replacement  *string  `yaml:"replacement,omitempty"`
+	Action       string   `yaml:"action,omitempty"`
+	// HashKey or the content of HashKeyFile is the secret key of the HMAC
+	// of the hash action, and HashLength the number of hex digits it keeps.
+	HashKey     string `yaml:"hash_key,omitempty"`
+	HashKeyFile string `yaml:"hash_key_file,omitempty"`
+	HashLength  int    `yaml:"hash_length,omitempty"`
+}
+
+// defaultHashLength is the number of hex digits kept by the hash action by
+// default, 64 bits of the hash.
+const defaultHashLength = 16
+
+// relabelRule is a RelabelConfig with its defaults filled in and its regex
+// compiled.
+type relabelRule struct {
+	sourceLabels []string
+	separator    string
+	regex        *regexp.Regexp
+	modulus      uint64
+	targetLabel  string
+	replacement  string
+	action       string
+	hashKey      []byte
+	hashLength   int
+}
+
+// labelNameProbes are label names that only a rule matching every label name
+// matches all of.
+var labelNameProbes = []string{"", "a", "Z", "_", "id", "a_b_9"}
+
+// Validate checks the rule for errors. It does not modify the rule.
+func (c *RelabelConfig) Validate() error {
+	_, err := c.compile()
+	return err
+}
+
+// compile returns the rule with its defaults filled in.
+func (c *RelabelConfig) compile() (*relabelRule, error) {
+	r := &relabelRule{
+		sourceLabels: c.SourceLabels,
+		separator:    ";",
+		modulus:      c.Modulus,
+		targetLabel:  c.TargetLabel,
+		replacement:  "$1",
+		action:       c.Action,
+		hashLength:   c.HashLength,
+	}
+	if c.Separator != nil {
+		r.separator = *c.Separator
+	}
+	if c.Replacement != nil {
+		r.replacement = *c.Replacement
+	}
+	if r.action == "" {
+		r.action = relabelReplace
+	}
+	regex := c.Regex
+	if regex == "" {
+		regex = "(.*)"
+	}
+	re, err := regexp.Compile("^(?:" + regex + ")$")
+	if err != nil {
+		return nil, fmt.Errorf("invalid regex %q: %w", regex, err)
+	}
+	r.regex = re
+
+	switch r.action {
+	case relabelReplace:
+		if r.targetLabel == "" {
+			return nil, fmt.Errorf("relabel action %q requires target_label", r.action)
+		}
+		// Every metric would get the same name, whatever its type.
+		if r.targetLabel == model.MetricNameLabel && !strings.Contains(r.replacement, "$") {
+			return nil, fmt.Errorf("relabel action %q must not set %s to a constant", r.action, model.MetricNameLabel)
+		}
+	case relabelHashMod:
+		if r.targetLabel == "" || r.modulus == 0 {
+			return nil, fmt.Errorf("relabel action %q requires target_label and modulus", r.action)
+		}
+	case relabelHash:
+		if r.targetLabel == "" && len(r.sourceLabels) != 1 {
+			return nil, fmt.Errorf("relabel action %q requires target_label unless there is exactly one source label", r.action)
+		}
+		if r.targetLabel == "" {
+			r.targetLabel = r.sourceLabels[0]
+		}
+		if c.HashKey != "" && c.HashKeyFile != "" {
+			return nil, errors.New("at most one of hash_key and hash_key_file must be configured")
+		}
+		if c.HashKey != "" {
+			r.hashKey = []byte(c.HashKey)
+		}
+		if c.HashKeyFile != "" {
+			key, err := readSecretFile(c.HashKeyFile)
+			if err != nil {
+				return nil, fmt.Errorf("unable to read hash key file %s: %w", c.HashKeyFile, err)
+			}
+			if key == "" {
+				return nil, fmt.Errorf("hash key file %s is empty", c.HashKeyFile)
+			}
+			r.hashKey = []byte(key)
+		}
+		if r.hashLength == 0 {
+			r.hashLength = defaultHashLength
+		}
+		if r.hashLength < 0 || r.hashLength > 2*sha256.Size {
+			return nil, fmt.Errorf("hash_length must be between 1 and %d", 2*sha256.Size)
+		}
+	case relabelKeep, relabelDrop:
+		if len(r.sourceLabels) == 0 {
+			return nil, fmt.Errorf("relabel action %q requires source_labels", r.action)
+		}
+	case relabelLabelDrop:
+		// Every series of a metric would be merged into one.
+		all := true
+		for _, name := range labelNameProbes {
+			all = all && re.MatchString(name)
+		}
+		if all {
+			return nil, fmt.Errorf("relabel action %q must not drop every label", r.action)
+		}
+	case relabelLabelMap:
+		if r.replacement == model.MetricNameLabel {
+			return nil, fmt.Errorf("relabel action %q must not map labels to %s", r.action, model.MetricNameLabel)
+		}
+	default:
+		return nil, fmt.Errorf("unknown relabel action %q", r.action)
+	}
+	if r.action != relabelHash && (c.HashKey != "" || c.HashKeyFile != "" || c.HashLength != 0) {
+		return nil, fmt.Errorf("relabel action %q does not use hash_key, hash_key_file or hash_length", r.action)
+	}
+	if (r.action == relabelHashMod || r.action == relabelHash) && r.targetLabel == model.MetricNameLabel {
+		return nil, fmt.Errorf("relabel action %q must not set %s", r.action, model.MetricNameLabel)
+	}
+	if r.targetLabel != "" && !model.LabelName(r.targetLabel).IsValid() && !strings.Contains(r.targetLabel, "$") {
+		return nil, fmt.Errorf("invalid target_label %q", r.targetLabel)
+	}
+	return r, nil
+}
+
+// compileRelabelConfigs returns the rules of configs.
+func compileRelabelConfigs(configs []*RelabelConfig) ([]*relabelRule, error) {
+	rules := make([]*relabelRule, 0, len(configs))
+	for _, c := range configs {
+		r, err := c.compile()
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, r)
+	}
+	return rules, nil
+}
+
+// relabel applies rules to labels in place. It returns false if the metric
+// is dropped.
+func relabel(rules []*relabelRule, labels map[string]string) bool {
+	for _, c := range rules {
+		values := make([]string, len(c.sourceLabels))
+		for i, name := range c.sourceLabels {
+			values[i] = labels[name]
+		}
+		value := strings.Join(values, c.separator)
+
+		switch c.action {
+		case relabelReplace:
+			m := c.regex.FindStringSubmatchIndex(value)
+			if m == nil {
+				continue
+			}
+			target := string(c.regex.ExpandString(nil, c.targetLabel, value, m))
+			if !model.LabelName(target).IsValid() {
+				continue
+			}
+			labels[target] = string(c.regex.ExpandString(nil, c.replacement, value, m))
+		case relabelKeep:
+			if !c.regex.MatchString(value) {
+				return false
+			}
+		case relabelDrop:
+			if c.regex.MatchString(value) {
+				return false
+			}
+		case relabelLabelDrop:
+			for name := range labels {
+				if name != model.MetricNameLabel && c.regex.MatchString(name) {
+					delete(labels, name)
+				}
+			}
+		case relabelLabelMap:
+			mapped := map[string]string{}
+			for name, v := range labels {
+				if m := c.regex.FindStringSubmatchIndex(name); m != nil {
+					if target := string(c.regex.ExpandString(nil, c.replacement, name, m)); model.LabelName(target).IsValid() {
+						mapped[target] = v
+					}
+				}
+			}
+			for name, v := range mapped {
+				labels[name] = v
+			}
+		case relabelHashMod:
+			sum := md5.Sum([]byte(value))
+			labels[c.targetLabel] = strconv.FormatUint(binary.BigEndian.Uint64(sum[8:])%c.modulus, 10)
+		case relabelHash:
+			if !c.regex.MatchString(value) {
+				continue
+			}
+			labels[c.targetLabel] = c.hash(value)
+		}
+	}
+	return true
+}
+
+// hash returns the first hex digits of the HMAC-SHA256 of value with the key
+// of the rule, or of its SHA-256 without a key.
+func (c *relabelRule) hash(value string) string {
+	var sum []byte
+	if c.hashKey != nil {
+		mac := hmac.New(sha256.New, c.hashKey)
+		mac.Write([]byte(value))
+		sum = mac.Sum(nil)
+	} else {
+		s := sha256.Sum256([]byte(value))
+		sum = s[:]
+	}
+	return hex.EncodeToString(sum)[:c.hashLength]
+}
@@ -0,0 +1,229 @@
+package main
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/prometheus/common/model"
+)
+
+func TestRelabel(t *testing.T) {
+	tests := []struct {
+		name   string
+		rule   RelabelConfig
+		labels map[string]string
+		want   map[string]string
+	}{
+		{
+			name:   "replace",
+			rule:   RelabelConfig{SourceLabels: []string{"id"}, Regex: "RTCTransport_(.*)", TargetLabel: "transport"},
+			labels: map[string]string{"id": "RTCTransport_0_1"},
+			want:   map[string]string{"id": "RTCTransport_0_1", "transport": "0_1"},
+		},
+		{
+			name:   "replace without match",
+			rule:   RelabelConfig{SourceLabels: []string{"id"}, Regex: "RTCCodec_.*", TargetLabel: "codec"},
+			labels: map[string]string{"id": "RTCTransport_0_1"},
+			want:   map[string]string{"id": "RTCTransport_0_1"},
+		},
+		{
+			name:   "keep",
+			rule:   RelabelConfig{SourceLabels: []string{"kind"}, Regex: "video", Action: "keep"},
+			labels: map[string]string{"kind": "audio"},
+		},
+		{
+			name:   "drop",
+			rule:   RelabelConfig{SourceLabels: []string{"__name__", "kind"}, Regex: "momo_inbound_rtp_.*;audio", Action: "drop"},
+			labels: map[string]string{"__name__": "momo_inbound_rtp_jitter", "kind": "audio"},
+		},
+		{
+			name:   "labeldrop",
+			rule:   RelabelConfig{Regex: ".*Implementation", Action: "labeldrop"},
+			labels: map[string]string{"id": "a", "decoderImplementation": "libvpx"},
+			want:   map[string]string{"id": "a"},
+		},
+		{
+			name:   "labelmap",
+			rule:   RelabelConfig{Regex: "remote(.*)", Replacement: stringPtr("peer$1"), Action: "labelmap"},
+			labels: map[string]string{"remoteSource": "true"},
+			want:   map[string]string{"remoteSource": "true", "peerSource": "true"},
+		},
+		{
+			name:   "hashmod",
+			rule:   RelabelConfig{SourceLabels: []string{"id"}, Modulus: 4, TargetLabel: "shard", Action: "hashmod"},
+			labels: map[string]string{"id": "RTCTransport_0_1"},
+			want:   map[string]string{"id": "RTCTransport_0_1", "shard": "3"},
+		},
+		{
+			name:   "hash",
+			rule:   RelabelConfig{SourceLabels: []string{"address"}, Action: "hash"},
+			labels: map[string]string{"address": "192.0.2.1"},
+			want:   map[string]string{"address": "37fcff24bf62035b"},
+		},
+		{
+			name:   "hash with key",
+			rule:   RelabelConfig{SourceLabels: []string{"address"}, Action: "hash", HashKey: "secret", HashLength: 8},
+			labels: map[string]string{"address": "192.0.2.1"},
+			want:   map[string]string{"address": "84edc408"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule, err := tt.rule.compile()
+			if err != nil {
+				t.Fatal(err)
+			}
+			kept := relabel([]*relabelRule{rule}, tt.labels)
+			if tt.want == nil {
+				if kept {
+					t.Errorf("got %v; want dropped", tt.labels)
+				}
+				return
+			}
+			if !kept || !reflect.DeepEqual(tt.labels, tt.want) {
+				t.Errorf("got %v (kept %v); want %v", tt.labels, kept, tt.want)
+			}
+		})
+	}
+}
+
+func TestRelabelConfigValidate(t *testing.T) {
+	for _, c := range []RelabelConfig{
+		{Action: "rename"},
+		{Regex: "("},
+		{SourceLabels: []string{"id"}},
+		{SourceLabels: []string{"id"}, TargetLabel: "shard", Action: "hashmod"},
+		{Action: "keep"},
+		{SourceLabels: []string{"id"}, TargetLabel: "0id"},
+		{TargetLabel: "__name__", Replacement: stringPtr("momo_metric")},
+		{Regex: ".*", Action: "labeldrop"},
+		{Regex: "(.*)", Replacement: stringPtr("__name__"), Action: "labelmap"},
+		{SourceLabels: []string{"id"}, TargetLabel: "__name__", Action: "hash"},
+		{SourceLabels: []string{"id"}, Action: "hash", HashKey: "secret", HashKeyFile: "test/config.yml"},
+		{SourceLabels: []string{"id"}, Action: "hash", HashKeyFile: "test/missing"},
+		{SourceLabels: []string{"id"}, Action: "hash", HashLength: 65},
+		{SourceLabels: []string{"id"}, Action: "hash", HashLength: -1},
+		{SourceLabels: []string{"id"}, TargetLabel: "shard", Modulus: 4, Action: "hashmod", HashKey: "secret"},
+	} {
+		c := c
+		if err := c.Validate(); err == nil {
+			t.Errorf("expected error for %+v", c)
+		}
+	}
+}
+
+func TestRelabelConfigValidateKeepsConfig(t *testing.T) {
+	c := RelabelConfig{SourceLabels: []string{"id"}, Action: "hash"}
+	want := c
+	if err := c.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(c, want) {
+		t.Errorf("got %+v after Validate; want %+v", c, want)
+	}
+}
+
+func TestRelabelHashKeyFile(t *testing.T) {
+	dir := t.TempDir()
+	keyFile := filepath.Join(dir, "key")
+	writeFile(t, keyFile, "secret\n")
+	c := RelabelConfig{SourceLabels: []string{"address"}, Action: "hash", HashKeyFile: keyFile}
+	rule, err := c.compile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	labels := map[string]string{"address": "192.0.2.1"}
+	relabel([]*relabelRule{rule}, labels)
+	if want := "84edc40821674d12"; labels["address"] != want {
+		t.Errorf("got hash %q; want %q", labels["address"], want)
+	}
+
+	writeFile(t, keyFile, "")
+	if err := c.Validate(); err == nil {
+		t.Error("expected error for an empty hash key file")
+	}
+}
+
+func stringPtr(s string) *string { return &s }
+
+func TestRelabelConfigs(t *testing.T) {
+	resp := `{
+		"version": "WebRTC Native Client Momo 2020.11 (db9d97e)",
+		"libwebrtc": "Shiguredo-Build M88.4324@{#2} (88.4324.2.0 54bd8488)",
+		"environment": "[aarch64] Ubuntu 18.04.5 LTS (nvidia-l4t-core 32.4.4-20201016123640)",
+		"stats": [
+			{
+				"bytesReceived": 1000,
+				"bytesSent": 2000,
+				"dtlsState": "connected",
+				"id": "RTCTransport_0_1",
+				"selectedCandidatePairId": "RTCIceCandidatePair_4",
+				"type": "transport"
+			}
+		]
+	}`
+	h := newMomo([]byte(resp))
+	defer h.Close()
+	e, err := NewExporter(TargetConfig{
+		URI:     h.URL,
+		Timeout: model.Duration(5 * time.Second),
+		ExportConfig: ExportConfig{
+			StatsTypes: []string{"transport"},
+			RelabelConfigs: []*RelabelConfig{
+				{SourceLabels: []string{"__name__"}, Regex: "momo_(up|transport_.*)", Action: "keep"},
+				{SourceLabels: []string{"id"}, Regex: "RTC.*", Action: "hash"},
+				{Regex: "selectedCandidatePairId|dtlsCipher|srtpCipher", Action: "labeldrop"},
+			},
+		},
+	}, log.NewNopLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	e.now = func() time.Time { return testTime }
+	expectMetrics(t, e, "relabel")
+}
+
+func TestRelabelConfigsMergeSeries(t *testing.T) {
+	resp := `{
+		"version": "WebRTC Native Client Momo 2020.11 (db9d97e)",
+		"libwebrtc": "Shiguredo-Build M88.4324@{#2} (88.4324.2.0 54bd8488)",
+		"environment": "[aarch64] Ubuntu 18.04.5 LTS (nvidia-l4t-core 32.4.4-20201016123640)",
+		"stats": [
+			{
+				"bytesReceived": 1000,
+				"bytesSent": 2000,
+				"dtlsState": "connected",
+				"id": "RTCTransport_0_1",
+				"type": "transport"
+			},
+			{
+				"bytesReceived": 300,
+				"bytesSent": 400,
+				"dtlsState": "connected",
+				"id": "RTCTransport_1_1",
+				"type": "transport"
+			}
+		]
+	}`
+	h := newMomo([]byte(resp))
+	defer h.Close()
+	e, err := NewExporter(TargetConfig{
+		URI:     h.URL,
+		Timeout: model.Duration(5 * time.Second),
+		ExportConfig: ExportConfig{
+			StatsTypes: []string{"transport"},
+			RelabelConfigs: []*RelabelConfig{
+				{SourceLabels: []string{"__name__"}, Regex: "momo_transport_(bytes_.*|security_info)", Action: "keep"},
+				{Regex: "id", Action: "labeldrop"},
+			},
+		},
+	}, log.NewNopLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	e.now = func() time.Time { return testTime }
+	expectMetrics(t, e, "relabel_merge")
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// relationFields are the stats fields holding the id of another stats object.
+var relationFields = []string{
+	"codecId",
+	"localCandidateId",
+	"localCertificateId",
+	"localId",
+	"mediaSourceId",
+	"playoutId",
+	"remoteCandidateId",
+	"remoteCertificateId",
+	"remoteId",
+	"selectedCandidatePairId",
+	"trackId",
+	"transportId",
+}
+
+var (
+	statsRelation = newDesc("stats", "relation",
+		"Reference from one stats object to another through the relation field, for joins in PromQL.",
+		[]string{"from_type", "from_id", "to_type", "to_id", "relation"})
+	statsDanglingReferences = newDesc("stats", "dangling_references",
+		"Number of references through the relation field to stats objects missing from the report.",
+		[]string{"relation"})
+)
+
+// exportRelationMetrics exports the references between the stats objects of
+// the enabled stats types.
+func (e *Exporter) exportRelationMetrics(stats []interface{}, index statsIndex, ch chan<- prometheus.Metric) {
+	dangling := make(map[string]int, len(relationFields))
+	for _, s := range stats {
+		m, ok := s.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		fromType, _ := m["type"].(string)
+		fromID, _ := m["id"].(string)
+		if !e.statsTypeEnabled(fromType) {
+			continue
+		}
+		if e.dropPerStreamRTP() && (fromType == "inbound-rtp" || fromType == "outbound-rtp") {
+			continue
+		}
+		for _, field := range relationFields {
+			toID, _ := m[field].(string)
+			if toID == "" {
+				continue
+			}
+			to, ok := index[toID]
+			if !ok {
+				dangling[field]++
+				continue
+			}
+			toType, _ := to["type"].(string)
+			e.sendConst(ch, statsRelation, prometheus.GaugeValue, 1, fromType, fromID, toType, toID, field)
+		}
+	}
+	for _, field := range relationFields {
+		e.sendConst(ch, statsDanglingReferences, prometheus.GaugeValue, float64(dangling[field]), field)
+	}
+}
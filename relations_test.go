@@ -0,0 +1,38 @@
+package main
+
+import "testing"
+
+func TestRelations(t *testing.T) {
+	resp := `{
+		"version": "WebRTC Native Client Momo 2020.11 (db9d97e)",
+		"libwebrtc": "Shiguredo-Build M88.4324@{#2} (88.4324.2.0 54bd8488)",
+		"environment": "[aarch64] Ubuntu 18.04.5 LTS (nvidia-l4t-core 32.4.4-20201016123640)",
+		"stats": [
+			{
+				"id": "RTCCodec_0_Outbound_102",
+				"mimeType": "video/H264",
+				"payloadType": 102,
+				"timestamp": 1608309189926189,
+				"transportId": "RTCTransport_0_1",
+				"type": "codec"
+			},
+			{
+				"id": "RTCTransport_0_1",
+				"selectedCandidatePairId": "RTCIceCandidatePair_vpgjsoAn_zQSEz4UN",
+				"timestamp": 1608309189926189,
+				"type": "transport"
+			},
+			{
+				"codecId": "RTCCodec_0_Outbound_102",
+				"id": "RTCOutboundRTPVideoStream_2372247626",
+				"kind": "video",
+				"mediaSourceId": "RTCVideoSource_1",
+				"remoteId": "RTCRemoteInboundRtpVideoStream_2372247626",
+				"timestamp": 1608309189926189,
+				"transportId": "RTCTransport_0_1",
+				"type": "outbound-rtp"
+			}
+		]
+	}`
+	compare(t, resp, "relations")
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// staticSource is the targetManager source of the targets declared in the
+// configuration file.
+const staticSource = "static"
+
+// reloader loads the configuration file into a targetManager and runs the
+// target discovery it declares. It can be triggered concurrently, e.g. by
+// SIGHUP and by /-/reload.
+type reloader struct {
+	filename string
+	targets  *targetManager
+	logger   log.Logger
+	now      func() time.Time
+
+	mtx              sync.Mutex
+	discoverers      []*discoverer
+	cancel           context.CancelFunc
+	wg               sync.WaitGroup
+	reloadSuccessful prometheus.Gauge
+	reloadTimestamp  prometheus.Gauge
+}
+
+func newReloader(filename string, targets *targetManager, logger log.Logger) *reloader {
+	return &reloader{
+		filename: filename,
+		targets:  targets,
+		logger:   logger,
+		now:      time.Now,
+		reloadSuccessful: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "exporter_config_last_reload_successful",
+			Help:      "Whether the last configuration reload attempt was successful.",
+		}),
+		reloadTimestamp: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "exporter_config_last_reload_success_timestamp_seconds",
+			Help:      "Timestamp of the last successful configuration reload.",
+		}),
+	}
+}
+
+// Reload validates the configuration file and swaps in its targets,
+// including the targets its discovery configurations currently find.
+func (r *reloader) Reload() error {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	level.Info(r.logger).Log("msg", "Loading configuration file", "file", r.filename)
+	err := r.reload()
+	if err != nil {
+		level.Error(r.logger).Log("msg", "Error reloading configuration", "file", r.filename, "err", err)
+		r.reloadSuccessful.Set(0)
+		return err
+	}
+
+	level.Info(r.logger).Log("msg", "Completed loading of configuration file", "file", r.filename)
+	r.reloadSuccessful.Set(1)
+	r.reloadTimestamp.Set(float64(r.now().UnixNano()) / 1e9)
+	return nil
+}
+
+func (r *reloader) reload() error {
+	cfg, err := LoadConfig(r.filename)
+	if err != nil {
+		return err
+	}
+
+	sources := map[string][]TargetConfig{staticSource: cfg.Targets}
+	var discoverers []*discoverer
+	for i, c := range cfg.FileSDConfigs {
+		source := fmt.Sprintf("file_sd/%d", i)
+		fd := newFileDiscoverer(c, log.With(r.logger, "discovery", source))
+		discoverers = append(discoverers, newDiscoverer(source, time.Duration(c.RefreshInterval), fd.discover, r.targets, r.logger))
+	}
+	for i, c := range cfg.ProcSDConfigs {
+		source := fmt.Sprintf("proc_sd/%d", i)
+		pd := newProcDiscoverer(c, log.With(r.logger, "discovery", source))
+		discoverers = append(discoverers, newDiscoverer(source, time.Duration(c.RefreshInterval), pd.discover, r.targets, r.logger))
+	}
+	for _, d := range discoverers {
+		d.last = d.discover()
+		sources[d.source] = d.last
+	}
+
+	// Stop the running discovery so that it cannot overwrite the new
+	// targets, and resume it if the new targets are rejected.
+	r.stopDiscovery()
+	if err := r.targets.Set(sources); err != nil {
+		r.startDiscovery(r.discoverers)
+		return err
+	}
+	r.startDiscovery(discoverers)
+	return nil
+}
+
+func (r *reloader) startDiscovery(discoverers []*discoverer) {
+	ctx, cancel := context.WithCancel(context.Background())
+	r.discoverers = discoverers
+	r.cancel = cancel
+	for _, d := range discoverers {
+		r.wg.Add(1)
+		go func(d *discoverer) {
+			defer r.wg.Done()
+			d.run(ctx)
+		}(d)
+	}
+}
+
+func (r *reloader) stopDiscovery() {
+	if r.cancel != nil {
+		r.cancel()
+	}
+	r.wg.Wait()
+}
+
+// Describe implements prometheus.Collector.
+func (r *reloader) Describe(ch chan<- *prometheus.Desc) {
+	ch <- r.reloadSuccessful.Desc()
+	ch <- r.reloadTimestamp.Desc()
+}
+
+// Collect implements prometheus.Collector.
+func (r *reloader) Collect(ch chan<- prometheus.Metric) {
+	ch <- r.reloadSuccessful
+	ch <- r.reloadTimestamp
+}
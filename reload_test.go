@@ -0,0 +1,100 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestReload(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "config.yml")
+	writeFile(t, filename, "targets:\n  - name: robot-1\n    uri: http://robot-1:8081/metrics\n")
+
+	targets := newTargetManager(log.NewNopLogger())
+	r := newReloader(filename, targets, log.NewNopLogger())
+	r.now = func() time.Time { return testTime }
+
+	if err := r.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := targets.targets["robot-1"]; !ok {
+		t.Error("expected robot-1 to be loaded")
+	}
+
+	writeFile(t, filename, "targets:\n  - name: robot-2\n")
+	if err := r.Reload(); err == nil {
+		t.Error("expected invalid config to fail")
+	}
+	if _, ok := targets.targets["robot-1"]; !ok {
+		t.Error("expected robot-1 to be kept after failed reload")
+	}
+
+	expected := `
+# HELP momo_exporter_config_last_reload_success_timestamp_seconds Timestamp of the last successful configuration reload.
+# TYPE momo_exporter_config_last_reload_success_timestamp_seconds gauge
+momo_exporter_config_last_reload_success_timestamp_seconds 1.60830919e+09
+# HELP momo_exporter_config_last_reload_successful Whether the last configuration reload attempt was successful.
+# TYPE momo_exporter_config_last_reload_successful gauge
+momo_exporter_config_last_reload_successful 0
+`
+	if err := testutil.CollectAndCompare(r, strings.NewReader(expected)); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestReloadFileSD(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "config.yml")
+	writeFile(t, filepath.Join(dir, "robots.json"), `[{"targets": ["robot-2:8081"]}]`)
+	writeFile(t, filename, `
+targets:
+  - name: robot-1
+    uri: http://robot-1:8081/metrics
+file_sd_configs:
+  - files: [`+filepath.Join(dir, "*.json")+`]
+`)
+
+	targets := newTargetManager(log.NewNopLogger())
+	r := newReloader(filename, targets, log.NewNopLogger())
+	if err := r.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	defer r.stopDiscovery()
+
+	for _, name := range []string{"robot-1", "robot-2:8081"} {
+		if _, ok := targets.targets[name]; !ok {
+			t.Errorf("expected target %q to be loaded", name)
+		}
+	}
+}
+
+func TestReloadHandler(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "config.yml")
+	writeFile(t, filename, "targets: []\n")
+	targets := newTargetManager(log.NewNopLogger())
+	r := newReloader(filename, targets, log.NewNopLogger())
+
+	for _, tt := range []struct {
+		name     string
+		reloader *reloader
+		method   string
+		want     int
+	}{
+		{"config file", r, http.MethodPost, http.StatusOK},
+		{"GET", r, http.MethodGet, http.StatusMethodNotAllowed},
+		{"no config file", nil, http.MethodPost, http.StatusBadRequest},
+	} {
+		mux := newServeMux("/metrics", targets, tt.reloader, log.NewNopLogger())
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest(tt.method, "/-/reload", nil))
+		if w.Code != tt.want {
+			t.Errorf("%s: want status %d, have %d", tt.name, tt.want, w.Code)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"github.com/koron/go-dproxy"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Reasons a layer of an outbound stream is not sending, in
+// momo_outbound_rtp_inactive_layers. Layers that are active but not sending
+// use the qualityLimitationReason of the layer, if any.
+const (
+	layerReasonDisabled   = "disabled"
+	layerReasonNotSending = "not_sending"
+)
+
+// layerInactiveReasons are the reasons exported for every media source, so
+// that the series go to 0 when the layers become active again.
+// https://www.w3.org/TR/webrtc-stats/#rtcqualitylimitationreason-enum
+var layerInactiveReasons = []string{layerReasonDisabled, layerReasonNotSending, "bandwidth", "cpu", "other"}
+
+var (
+	outboundRTPActive = newDesc("outbound_rtp", "active",
+		"Whether this simulcast or SVC layer is configured to send.", outboundRTPLabelNames)
+
+	layerSummaryLabelNames = []string{"mediaSourceId"}
+	outboundRTPLayers      = newDesc("outbound_rtp", "layers",
+		"Number of outbound video RTP layers sent from this media source.", layerSummaryLabelNames)
+	outboundRTPActiveLayers = newDesc("outbound_rtp", "active_layers",
+		"Number of outbound video RTP layers from this media source that are sending frames.", layerSummaryLabelNames)
+	outboundRTPInactiveLayers = newDesc("outbound_rtp", "inactive_layers",
+		"Number of outbound video RTP layers from this media source that are not sending frames, by reason.", append(append([]string{}, layerSummaryLabelNames...), "reason"))
+)
+
+// layerSummary counts the layers of the video sent from one media source.
+type layerSummary struct {
+	layers   int
+	active   int
+	inactive map[string]int
+}
+
+// layerInactiveReason returns why an outbound layer is not sending frames,
+// or "" if it is.
+func layerInactiveReason(m dproxy.Proxy) string {
+	if active, err := m.M("active").Bool(); err == nil && !active {
+		return layerReasonDisabled
+	}
+	if fps, _ := m.M("framesPerSecond").Float64(); fps > 0 {
+		return ""
+	}
+	if reason, _ := m.M("qualityLimitationReason").String(); reason != "" && reason != "none" {
+		return reason
+	}
+	return layerReasonNotSending
+}
+
+// exportLayerSummaryMetrics summarizes the video layers sent from each media
+// source, so that simulcast and SVC streams show how many layers are sending.
+func (e *Exporter) exportLayerSummaryMetrics(stats []interface{}, ch chan<- prometheus.Metric) {
+	summaries := map[string]*layerSummary{}
+	for _, s := range stats {
+		m := dproxy.New(s)
+		if t, _ := m.M("type").String(); t != "outbound-rtp" {
+			continue
+		}
+		if kind, _ := m.M("kind").String(); kind != "video" {
+			continue
+		}
+		source, _ := m.M("mediaSourceId").String()
+		summary, ok := summaries[source]
+		if !ok {
+			summary = &layerSummary{inactive: map[string]int{}}
+			summaries[source] = summary
+		}
+		summary.layers++
+		if reason := layerInactiveReason(m); reason != "" {
+			summary.inactive[reason]++
+		} else {
+			summary.active++
+		}
+	}
+
+	for source, summary := range summaries {
+		e.sendConst(ch, outboundRTPLayers, prometheus.GaugeValue, float64(summary.layers), source)
+		e.sendConst(ch, outboundRTPActiveLayers, prometheus.GaugeValue, float64(summary.active), source)
+		for _, reason := range layerInactiveReasons {
+			e.sendConst(ch, outboundRTPInactiveLayers, prometheus.GaugeValue, float64(summary.inactive[reason]), source, reason)
+			delete(summary.inactive, reason)
+		}
+		for reason, n := range summary.inactive {
+			e.sendConst(ch, outboundRTPInactiveLayers, prometheus.GaugeValue, float64(n), source, reason)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestSimulcast(t *testing.T) {
+	resp := `{
+		"version": "WebRTC Native Client Momo 2022.4.1 (f5eb26a4)",
+		"libwebrtc": "Shiguredo-Build M103.5060@{#4} (103.5060.4.0 a8c5aa5c)",
+		"environment": "[x86_64] Ubuntu 20.04.4 LTS",
+		"stats": [
+			{
+				"active": true,
+				"bytesSent": 81201,
+				"codecId": "RTCCodec_0_Outbound_96",
+				"frameHeight": 180,
+				"frameWidth": 320,
+				"framesPerSecond": 30,
+				"id": "RTCOutboundRTPVideoStream_1001",
+				"kind": "video",
+				"mediaSourceId": "RTCVideoSource_2",
+				"mid": "1",
+				"qualityLimitationReason": "none",
+				"rid": "r0",
+				"scalabilityMode": "L1T3",
+				"timestamp": 1656324001000000,
+				"type": "outbound-rtp"
+			},
+			{
+				"active": true,
+				"bytesSent": 20443,
+				"codecId": "RTCCodec_0_Outbound_96",
+				"frameHeight": 360,
+				"frameWidth": 640,
+				"id": "RTCOutboundRTPVideoStream_1002",
+				"kind": "video",
+				"mediaSourceId": "RTCVideoSource_2",
+				"mid": "1",
+				"qualityLimitationReason": "bandwidth",
+				"rid": "r1",
+				"scalabilityMode": "L1T3",
+				"timestamp": 1656324001000000,
+				"type": "outbound-rtp"
+			},
+			{
+				"active": false,
+				"bytesSent": 0,
+				"codecId": "RTCCodec_0_Outbound_96",
+				"id": "RTCOutboundRTPVideoStream_1003",
+				"kind": "video",
+				"mediaSourceId": "RTCVideoSource_2",
+				"mid": "1",
+				"qualityLimitationReason": "none",
+				"rid": "r2",
+				"timestamp": 1656324001000000,
+				"type": "outbound-rtp"
+			}
+		]
+	}`
+	compare(t, resp, "simulcast")
+}
+
+func TestSimulcastLayersActiveAgain(t *testing.T) {
+	h := newMomo([]byte(`{
+		"stats": [
+			{
+				"active": true,
+				"framesPerSecond": 30,
+				"id": "RTCOutboundRTPVideoStream_1001",
+				"kind": "video",
+				"mediaSourceId": "RTCVideoSource_2",
+				"type": "outbound-rtp"
+			}
+		]
+	}`))
+	defer h.Close()
+	e := newTestExporter(t, h.URL, 5*time.Second)
+
+	expected := `
+# HELP momo_outbound_rtp_inactive_layers Number of outbound video RTP layers from this media source that are not sending frames, by reason.
+# TYPE momo_outbound_rtp_inactive_layers gauge
+momo_outbound_rtp_inactive_layers{mediaSourceId="RTCVideoSource_2",reason="bandwidth"} 0
+momo_outbound_rtp_inactive_layers{mediaSourceId="RTCVideoSource_2",reason="cpu"} 0
+momo_outbound_rtp_inactive_layers{mediaSourceId="RTCVideoSource_2",reason="disabled"} 0
+momo_outbound_rtp_inactive_layers{mediaSourceId="RTCVideoSource_2",reason="not_sending"} 0
+momo_outbound_rtp_inactive_layers{mediaSourceId="RTCVideoSource_2",reason="other"} 0
+`
+	if err := testutil.CollectAndCompare(e, strings.NewReader(expected), "momo_outbound_rtp_inactive_layers"); err != nil {
+		t.Error(err)
+	}
+}
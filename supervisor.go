@@ -0,0 +1,209 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// supervisor runs WebRTC Native Client Momo as a child process. It restarts
+// the child with exponential backoff when it exits and forwards signals to it.
+type supervisor struct {
+	args       []string
+	minBackoff time.Duration
+	maxBackoff time.Duration
+	logger     log.Logger
+
+	mtx sync.Mutex
+	cmd *exec.Cmd
+
+	restarts prometheus.Counter
+	exitCode prometheus.Gauge
+}
+
+func newSupervisor(args []string, minBackoff, maxBackoff time.Duration, logger log.Logger) *supervisor {
+	return &supervisor{
+		args:       args,
+		minBackoff: minBackoff,
+		maxBackoff: maxBackoff,
+		logger:     logger,
+		restarts: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "process",
+			Name:      "restarts_total",
+			Help:      "Number of times the WebRTC Native Client Momo process was restarted.",
+		}),
+		exitCode: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "process",
+			Name:      "last_exit_code",
+			Help:      "Exit code of the last WebRTC Native Client Momo process that exited, or 128 plus the signal number if it was killed by a signal.",
+		}),
+	}
+}
+
+// withMetricsPort returns the Momo command line args serving the metrics API
+// and its port. When args do not enable the metrics API, --metrics-port
+// port is injected right after the executable.
+func withMetricsPort(args []string, port int) ([]string, int) {
+	if p, ok := parseMomoCmdline(args); ok {
+		return args, p.metricsPort
+	}
+	injected := make([]string, 0, len(args)+2)
+	injected = append(injected, args[0], "--metrics-port", strconv.Itoa(port))
+	return append(injected, args[1:]...), port
+}
+
+// PID returns the process ID of the running child, or 0.
+func (s *supervisor) PID() int {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	if s.cmd == nil || s.cmd.Process == nil {
+		return 0
+	}
+	return s.cmd.Process.Pid
+}
+
+// Run runs the child until a terminating signal is received on signals, and
+// returns the exit code of the last child. Every signal is forwarded to the
+// child; SIGINT, SIGTERM and SIGQUIT stop the restarts. Only a failure to
+// start the first child is fatal; later failures are retried like exits.
+func (s *supervisor) Run(signals <-chan os.Signal) int {
+	backoff := s.minBackoff
+	for first := true; ; first = false {
+		started := time.Now()
+		stopping := false
+		var code int
+		done, err := s.start()
+		if err != nil {
+			if first {
+				level.Error(s.logger).Log("msg", "Error starting Momo", "err", err)
+				return 1
+			}
+			// The executable may be being replaced, e.g. by an upgrade.
+			code = startErrorCode(err)
+			level.Error(s.logger).Log("msg", "Error restarting Momo", "code", code, "err", err)
+		} else {
+		wait:
+			for {
+				select {
+				case sig := <-signals:
+					stopping = stopping || isTerminating(sig)
+					s.signal(sig)
+				case code = <-done:
+					break wait
+				}
+			}
+			level.Info(s.logger).Log("msg", "Momo exited", "code", code)
+		}
+		s.exitCode.Set(float64(code))
+
+		if stopping {
+			return code
+		}
+
+		// A child that ran for longer than the maximum backoff is
+		// considered healthy, so the backoff starts over.
+		if time.Since(started) > s.maxBackoff {
+			backoff = s.minBackoff
+		}
+		level.Info(s.logger).Log("msg", "Restarting Momo", "backoff", backoff)
+		timer := time.NewTimer(backoff)
+	sleep:
+		for {
+			select {
+			case sig := <-signals:
+				// There is no child to forward other signals to.
+				if isTerminating(sig) {
+					timer.Stop()
+					return code
+				}
+			case <-timer.C:
+				break sleep
+			}
+		}
+		backoff *= 2
+		if backoff > s.maxBackoff {
+			backoff = s.maxBackoff
+		}
+		s.restarts.Inc()
+	}
+}
+
+// startErrorCode returns the exit code shells use when they fail to run a
+// command with err: 127 if it does not exist, 126 otherwise.
+func startErrorCode(err error) int {
+	if errors.Is(err, os.ErrNotExist) || errors.Is(err, exec.ErrNotFound) {
+		return 127
+	}
+	return 126
+}
+
+// start starts the child and returns a channel receiving its exit code.
+func (s *supervisor) start() (<-chan int, error) {
+	cmd := exec.Command(s.args[0], s.args[1:]...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	level.Info(s.logger).Log("msg", "Started Momo", "pid", cmd.Process.Pid, "cmd", cmd.String())
+
+	s.mtx.Lock()
+	s.cmd = cmd
+	s.mtx.Unlock()
+
+	done := make(chan int, 1)
+	go func() {
+		cmd.Wait()
+		s.mtx.Lock()
+		s.cmd = nil
+		s.mtx.Unlock()
+		done <- exitCode(cmd.ProcessState)
+	}()
+	return done, nil
+}
+
+// exitCode returns the exit code of a process, or 128 plus the signal number
+// if it was killed by a signal, as shells do.
+func exitCode(state *os.ProcessState) int {
+	if ws, ok := state.Sys().(syscall.WaitStatus); ok && ws.Signaled() {
+		return 128 + int(ws.Signal())
+	}
+	return state.ExitCode()
+}
+
+func (s *supervisor) signal(sig os.Signal) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	if s.cmd == nil || s.cmd.Process == nil {
+		return
+	}
+	if err := s.cmd.Process.Signal(sig); err != nil {
+		level.Warn(s.logger).Log("msg", "Error forwarding signal to Momo", "signal", sig, "err", err)
+	}
+}
+
+func isTerminating(sig os.Signal) bool {
+	return sig == syscall.SIGINT || sig == syscall.SIGTERM || sig == syscall.SIGQUIT
+}
+
+// Describe implements prometheus.Collector.
+func (s *supervisor) Describe(ch chan<- *prometheus.Desc) {
+	ch <- s.restarts.Desc()
+	ch <- s.exitCode.Desc()
+}
+
+// Collect implements prometheus.Collector.
+func (s *supervisor) Collect(ch chan<- prometheus.Metric) {
+	ch <- s.restarts
+	ch <- s.exitCode
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestWithMetricsPort(t *testing.T) {
+	for _, tc := range []struct {
+		args     string
+		wantArgs string
+		wantPort int
+	}{
+		{"./momo test", "./momo --metrics-port 9000 test", 9000},
+		{"./momo --metrics-port=8082 sora --channel-id robot-1", "./momo --metrics-port=8082 sora --channel-id robot-1", 8082},
+	} {
+		args, port := withMetricsPort(strings.Fields(tc.args), 9000)
+		if want := strings.Fields(tc.wantArgs); !reflect.DeepEqual(args, want) || port != tc.wantPort {
+			t.Errorf("%q: want %v on port %d, have %v on port %d", tc.args, want, tc.wantPort, args, port)
+		}
+	}
+}
+
+// runSupervisor runs s in the background and returns a channel receiving
+// its exit code.
+func runSupervisor(s *supervisor, signals <-chan os.Signal) <-chan int {
+	done := make(chan int, 1)
+	go func() {
+		done <- s.Run(signals)
+	}()
+	return done
+}
+
+func waitForExit(t *testing.T, done <-chan int) int {
+	select {
+	case code := <-done:
+		return code
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the supervisor to exit")
+		return 0
+	}
+}
+
+// waitForFile waits until the child creates name, once it is ready for the
+// signals sent by the test.
+func waitForFile(t *testing.T, name string) {
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if _, err := os.Stat(name); err == nil {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", name)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestSupervisorRestarts(t *testing.T) {
+	// The child exits with 3 on its first three runs. The fourth run waits
+	// for SIGTERM, and exits with 3 as well.
+	runs := filepath.Join(t.TempDir(), "runs")
+	script := `n=$(cat "$0" 2>/dev/null || echo 0); echo $((n+1)) > "$0"; [ "$n" -lt 3 ] && exit 3; trap "exit 3" TERM; touch "$0.ready"; while :; do sleep 0.01; done`
+	s := newSupervisor([]string{"sh", "-c", script, runs}, time.Millisecond, 10*time.Millisecond, log.NewNopLogger())
+	signals := make(chan os.Signal, 1)
+	done := runSupervisor(s, signals)
+
+	waitForFile(t, runs+".ready")
+	signals <- syscall.SIGTERM
+
+	if code := waitForExit(t, done); code != 3 {
+		t.Errorf("want exit code 3, have %d", code)
+	}
+	if code := testutil.ToFloat64(s.exitCode); code != 3 {
+		t.Errorf("want last exit code 3, have %v", code)
+	}
+	if restarts := testutil.ToFloat64(s.restarts); restarts != 3 {
+		t.Errorf("want 3 restarts, have %v", restarts)
+	}
+}
+
+func TestSupervisorForwardsSignals(t *testing.T) {
+	ready := filepath.Join(t.TempDir(), "ready")
+	s := newSupervisor([]string{"sh", "-c", `trap "exit 7" TERM; touch "$0"; while :; do sleep 0.01; done`, ready}, time.Millisecond, time.Second, log.NewNopLogger())
+	signals := make(chan os.Signal, 1)
+	done := runSupervisor(s, signals)
+
+	waitForFile(t, ready)
+	signals <- syscall.SIGTERM
+
+	if code := waitForExit(t, done); code != 7 {
+		t.Errorf("want exit code 7, have %d", code)
+	}
+	if restarts := testutil.ToFloat64(s.restarts); restarts != 0 {
+		t.Errorf("want no restarts after SIGTERM, have %v", restarts)
+	}
+}
+
+func TestSupervisorKilledBySignal(t *testing.T) {
+	s := newSupervisor([]string{"sleep", "10"}, time.Millisecond, time.Second, log.NewNopLogger())
+	signals := make(chan os.Signal, 1)
+	done := runSupervisor(s, signals)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for s.PID() == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for the child to start")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	signals <- syscall.SIGTERM
+
+	if code := waitForExit(t, done); code != 128+int(syscall.SIGTERM) {
+		t.Errorf("want exit code %d, have %d", 128+int(syscall.SIGTERM), code)
+	}
+}
+
+// waitForGauge waits until g has value v.
+func waitForGauge(t *testing.T, g prometheus.Gauge, v float64) {
+	deadline := time.Now().Add(5 * time.Second)
+	for testutil.ToFloat64(g) != v {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %v, have %v", v, testutil.ToFloat64(g))
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestSupervisorRestartFails(t *testing.T) {
+	// The child removes itself, as if it was being replaced, so restarts
+	// fail until it is back.
+	momo := filepath.Join(t.TempDir(), "momo")
+	writeFile(t, momo, "#!/bin/sh\nrm \"$0\"\nexit 3\n")
+	if err := os.Chmod(momo, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	s := newSupervisor([]string{momo}, time.Millisecond, 10*time.Millisecond, log.NewNopLogger())
+	signals := make(chan os.Signal, 1)
+	done := runSupervisor(s, signals)
+
+	waitForGauge(t, s.exitCode, 127)
+	signals <- syscall.SIGTERM
+	if code := waitForExit(t, done); code != 127 {
+		t.Errorf("want exit code 127, have %d", code)
+	}
+	if restarts := testutil.ToFloat64(s.restarts); restarts < 1 {
+		t.Errorf("want restarts, have %v", restarts)
+	}
+}
+
+func TestSupervisorBackoffIgnoresSignals(t *testing.T) {
+	s := newSupervisor([]string{"sh", "-c", "exit 3"}, time.Hour, time.Hour, log.NewNopLogger())
+	signals := make(chan os.Signal, 1)
+	done := runSupervisor(s, signals)
+
+	waitForGauge(t, s.exitCode, 3)
+	signals <- syscall.SIGHUP
+	time.Sleep(50 * time.Millisecond)
+	if restarts := testutil.ToFloat64(s.restarts); restarts != 0 {
+		t.Errorf("want no restart before the backoff elapsed, have %v", restarts)
+	}
+
+	signals <- syscall.SIGTERM
+	if code := waitForExit(t, done); code != 3 {
+		t.Errorf("want exit code 3, have %d", code)
+	}
+}
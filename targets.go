@@ -0,0 +1,157 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// targetManager exports a set of targets, each scraped by its own Exporter.
+// Targets are grouped by the source that provides them, such as the
+// configuration file or a discovery mechanism, and each source can replace
+// its targets while the exporter is running. It implements
+// prometheus.Gatherer.
+type targetManager struct {
+	logger log.Logger
+
+	// updateMtx serializes updates; mtx protects the fields below.
+	updateMtx sync.Mutex
+	mtx       sync.RWMutex
+	sources   map[string][]TargetConfig
+	targets   map[string]*target
+	registry  *prometheus.Registry
+}
+
+type target struct {
+	config   TargetConfig
+	labels   map[string]string
+	exporter *Exporter
+}
+
+func newTargetManager(logger log.Logger) *targetManager {
+	return &targetManager{
+		logger:   logger,
+		sources:  map[string][]TargetConfig{},
+		targets:  map[string]*target{},
+		registry: prometheus.NewRegistry(),
+	}
+}
+
+// Set atomically replaces the targets of all sources.
+func (m *targetManager) Set(sources map[string][]TargetConfig) error {
+	m.updateMtx.Lock()
+	defer m.updateMtx.Unlock()
+	return m.apply(sources)
+}
+
+// Update atomically replaces the targets provided by source.
+func (m *targetManager) Update(source string, configs []TargetConfig) error {
+	m.updateMtx.Lock()
+	defer m.updateMtx.Unlock()
+
+	m.mtx.RLock()
+	sources := make(map[string][]TargetConfig, len(m.sources)+1)
+	for s, c := range m.sources {
+		sources[s] = c
+	}
+	m.mtx.RUnlock()
+
+	if len(configs) == 0 {
+		delete(sources, source)
+	} else {
+		sources[source] = configs
+	}
+	return m.apply(sources)
+}
+
+// apply builds the exporters for sources and swaps them in. Exporters of
+// targets whose configuration did not change are kept, so their counters
+// survive. On error the previous targets stay in place. When two sources
+// provide a target with the same name, the one from the source sorting
+// first wins. Likewise, targets whose metrics would have other label names
+// than those of the targets before them are ignored.
+//
+// Every metric of a target is labelled with the target name and the target's
+// static labels. Static labels missing from a target are set to the empty
+// string so that all targets share the same label names.
+func (m *targetManager) apply(sources map[string][]TargetConfig) error {
+	names := make([]string, 0, len(sources))
+	for s := range sources {
+		names = append(names, s)
+	}
+	sort.Strings(names)
+
+	var configs []TargetConfig
+	seen := map[string]string{}
+	for _, s := range names {
+		for _, c := range sources[s] {
+			if other, ok := seen[c.Name]; ok {
+				level.Warn(m.logger).Log("msg", "Ignoring duplicate target", "target", c.Name, "source", s, "previous_source", other)
+				continue
+			}
+			if err := checkConsistentTarget(configs, &c); err != nil {
+				level.Warn(m.logger).Log("msg", "Ignoring inconsistent target", "source", s, "err", err)
+				continue
+			}
+			seen[c.Name] = s
+			configs = append(configs, c)
+		}
+	}
+
+	labelNames := map[string]bool{}
+	for _, c := range configs {
+		for name := range c.Labels {
+			labelNames[name] = true
+		}
+	}
+
+	m.mtx.RLock()
+	old := m.targets
+	m.mtx.RUnlock()
+
+	targets := make(map[string]*target, len(configs))
+	reg := prometheus.NewRegistry()
+	for _, c := range configs {
+		labels := c.labels()
+		for name := range labelNames {
+			if _, ok := labels[name]; !ok {
+				labels[name] = ""
+			}
+		}
+
+		t, ok := old[c.Name]
+		if !ok || !reflect.DeepEqual(t.config, c) || !reflect.DeepEqual(t.labels, labels) {
+			e, err := NewExporter(c, log.With(m.logger, "target", c.Name))
+			if err != nil {
+				return fmt.Errorf("target %q: %w", c.Name, err)
+			}
+			t = &target{config: c, labels: labels, exporter: e}
+		}
+		if err := prometheus.WrapRegistererWith(t.labels, reg).Register(t.exporter); err != nil {
+			return fmt.Errorf("target %q: %w", c.Name, err)
+		}
+		targets[c.Name] = t
+	}
+
+	m.mtx.Lock()
+	m.sources = sources
+	m.targets = targets
+	m.registry = reg
+	m.mtx.Unlock()
+	return nil
+}
+
+// Gather implements prometheus.Gatherer. The registry collects the targets
+// concurrently.
+func (m *targetManager) Gather() ([]*dto.MetricFamily, error) {
+	m.mtx.RLock()
+	reg := m.registry
+	m.mtx.RUnlock()
+	return reg.Gather()
+}
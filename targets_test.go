@@ -0,0 +1,118 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/go-kit/kit/log"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+const peerConnectionResponse = `{
+	"version": "WebRTC Native Client Momo 2020.11 (db9d97e)",
+	"libwebrtc": "Shiguredo-Build M88.4324@{#2} (88.4324.2.0 54bd8488)",
+	"environment": "[aarch64] Ubuntu 18.04.5 LTS (nvidia-l4t-core 32.4.4-20201016123640)",
+	"stats": [
+		{
+			"dataChannelsClosed": 0,
+			"dataChannelsOpened": 1,
+			"id": "RTCPeerConnection",
+			"timestamp": 1608309189926189,
+			"type": "peer-connection"
+		}
+	]
+}`
+
+func TestTargetManager(t *testing.T) {
+	robot1 := newMomo([]byte(peerConnectionResponse))
+	defer robot1.Close()
+	robot2 := newMomo([]byte(peerConnectionResponse))
+	defer robot2.Close()
+
+	cfg := &Config{
+		Targets: []TargetConfig{
+			{Name: "robot-1", URI: robot1.URL, Labels: map[string]string{"site": "lab"}},
+			{Name: "robot-2", URI: robot2.URL, ExportConfig: ExportConfig{StatsTypes: []string{"transport"}}},
+		},
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	reg := newTargetManager(log.NewNopLogger())
+	if err := reg.Update(staticSource, cfg.Targets); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `
+# HELP momo_peerconnection_data_channels_opened_total Number of unique RTCDataChannels that have entered the "open" state during their lifetime.
+# TYPE momo_peerconnection_data_channels_opened_total counter
+momo_peerconnection_data_channels_opened_total{id="RTCPeerConnection",site="lab",target="robot-1"} 1
+# HELP momo_up Was the last scrape of WebRTC Native Client Momo successful.
+# TYPE momo_up gauge
+momo_up{site="lab",target="robot-1"} 1
+momo_up{site="",target="robot-2"} 1
+`
+	if err := testutil.GatherAndCompare(reg, strings.NewReader(expected), "momo_up", "momo_peerconnection_data_channels_opened_total"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestTargetManagerUpdate(t *testing.T) {
+	robot1 := newMomo([]byte(peerConnectionResponse))
+	defer robot1.Close()
+	robot2 := newMomo([]byte(peerConnectionResponse))
+	defer robot2.Close()
+
+	m := newTargetManager(log.NewNopLogger())
+	if err := m.Update(staticSource, []TargetConfig{{Name: "robot-1", URI: robot1.URL}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Gather(); err != nil {
+		t.Fatal(err)
+	}
+
+	// robot-1 is unchanged and keeps its counters, robot-2 is new.
+	if err := m.Update(staticSource, []TargetConfig{{Name: "robot-1", URI: robot1.URL}, {Name: "robot-2", URI: robot2.URL}}); err != nil {
+		t.Fatal(err)
+	}
+	expected := `
+# HELP momo_exporter_scrapes_total Current total momo scrapes.
+# TYPE momo_exporter_scrapes_total counter
+momo_exporter_scrapes_total{target="robot-1"} 2
+momo_exporter_scrapes_total{target="robot-2"} 1
+`
+	if err := testutil.GatherAndCompare(m, strings.NewReader(expected), "momo_exporter_scrapes_total"); err != nil {
+		t.Error(err)
+	}
+
+	// A failing update keeps the previous targets.
+	conflict := []TargetConfig{{Name: "robot-3", URI: robot1.URL, Labels: map[string]string{"id": "x"}}}
+	if err := m.Update(staticSource, conflict); err == nil {
+		t.Error("expected error for static label conflicting with a metric label")
+	}
+	if _, ok := m.targets["robot-3"]; ok || len(m.targets) != 2 {
+		t.Errorf("want previous targets after failed update, have %v", m.targets)
+	}
+}
+
+func TestTargetManagerInconsistentTarget(t *testing.T) {
+	robot1 := newMomo([]byte(peerConnectionResponse))
+	defer robot1.Close()
+	robot2 := newMomo([]byte(peerConnectionResponse))
+	defer robot2.Close()
+
+	m := newTargetManager(log.NewNopLogger())
+	err := m.Set(map[string][]TargetConfig{
+		"a": {{Name: "robot-1", URI: robot1.URL, ExportConfig: ExportConfig{DataChannelLabels: []string{"protocol"}}}},
+		"b": {{Name: "robot-2", URI: robot2.URL}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := m.targets["robot-2"]; ok || len(m.targets) != 1 {
+		t.Errorf("want only robot-1, have %v", m.targets)
+	}
+	if _, err := m.Gather(); err != nil {
+		t.Error(err)
+	}
+}
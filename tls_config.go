@@ -0,0 +1,194 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sync"
+)
+
+// TLSVersion is a TLS protocol version as used in tls.Config.
+type TLSVersion uint16
+
+// TLSVersions maps the names accepted in flags and configuration to TLS versions.
+var TLSVersions = map[string]TLSVersion{
+	"TLS13": tls.VersionTLS13,
+	"TLS12": tls.VersionTLS12,
+	"TLS11": tls.VersionTLS11,
+	"TLS10": tls.VersionTLS10,
+}
+
+// ParseTLSVersion returns the TLSVersion named s, such as "TLS12".
+func ParseTLSVersion(s string) (TLSVersion, error) {
+	if v, ok := TLSVersions[s]; ok {
+		return v, nil
+	}
+	return 0, fmt.Errorf("unknown TLS version: %s", s)
+}
+
+// UnmarshalYAML implements yaml.Unmarshaler.
+func (v *TLSVersion) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var s string
+	if err := unmarshal(&s); err != nil {
+		return err
+	}
+	tv, err := ParseTLSVersion(s)
+	if err != nil {
+		return err
+	}
+	*v = tv
+	return nil
+}
+
+// TLSConfig configures the TLS connection to WebRTC Native Client Momo.
+type TLSConfig struct {
+	CAFile             string     `yaml:"ca_file,omitempty"`
+	CertFile           string     `yaml:"cert_file,omitempty"`
+	KeyFile            string     `yaml:"key_file,omitempty"`
+	ServerName         string     `yaml:"server_name,omitempty"`
+	InsecureSkipVerify bool       `yaml:"insecure_skip_verify"`
+	MinVersion         TLSVersion `yaml:"min_version,omitempty"`
+}
+
+// Validate checks that the client certificate and key are configured together.
+func (c *TLSConfig) Validate() error {
+	if c.CertFile != "" && c.KeyFile == "" {
+		return fmt.Errorf("client cert file %q specified without client key file", c.CertFile)
+	}
+	if c.KeyFile != "" && c.CertFile == "" {
+		return fmt.Errorf("client key file %q specified without client cert file", c.KeyFile)
+	}
+	return nil
+}
+
+func (c *TLSConfig) hasFiles() bool {
+	return c.CAFile != "" || c.CertFile != ""
+}
+
+// newTLSConfig builds a tls.Config from c, loading the CA bundle and client
+// certificate from disk.
+func newTLSConfig(c *TLSConfig) (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		InsecureSkipVerify: c.InsecureSkipVerify,
+		ServerName:         c.ServerName,
+		MinVersion:         uint16(c.MinVersion),
+	}
+
+	if c.CAFile != "" {
+		b, err := ioutil.ReadFile(c.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read CA file %s: %w", c.CAFile, err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(b) {
+			return nil, fmt.Errorf("unable to use specified CA file %s", c.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if c.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("unable to use specified client cert (%s) and key (%s): %w", c.CertFile, c.KeyFile, err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsConfig, nil
+}
+
+// tlsRoundTripper rebuilds its underlying round tripper whenever the CA,
+// certificate or key file changes on disk, so rotated certificates are used
+// without restarting the exporter.
+type tlsRoundTripper struct {
+	config TLSConfig
+	newRT  func(*tls.Config) http.RoundTripper
+
+	mtx    sync.RWMutex
+	rt     http.RoundTripper
+	hashes []byte
+}
+
+func newTLSRoundTripper(config TLSConfig, newRT func(*tls.Config) http.RoundTripper) (http.RoundTripper, error) {
+	t := &tlsRoundTripper{config: config, newRT: newRT}
+	if err := t.reload(); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *tlsRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	hashes, err := t.fileHashes()
+	if err != nil {
+		return nil, tlsFilesError{err}
+	}
+
+	t.mtx.RLock()
+	changed := !bytes.Equal(hashes, t.hashes)
+	t.mtx.RUnlock()
+
+	if changed {
+		if err := t.reload(); err != nil {
+			return nil, tlsFilesError{err}
+		}
+	}
+
+	t.mtx.RLock()
+	rt := t.rt
+	t.mtx.RUnlock()
+	return rt.RoundTrip(req)
+}
+
+// tlsFilesError is returned by tlsRoundTripper when the CA, certificate or
+// key file cannot be loaded, e.g. while it is being rotated.
+type tlsFilesError struct {
+	err error
+}
+
+func (e tlsFilesError) Error() string { return e.err.Error() }
+func (e tlsFilesError) Unwrap() error { return e.err }
+
+func (t *tlsRoundTripper) reload() error {
+	hashes, err := t.fileHashes()
+	if err != nil {
+		return err
+	}
+	tlsConfig, err := newTLSConfig(&t.config)
+	if err != nil {
+		return err
+	}
+	rt := t.newRT(tlsConfig)
+
+	t.mtx.Lock()
+	old := t.rt
+	t.rt = rt
+	t.hashes = hashes
+	t.mtx.Unlock()
+
+	if c, ok := old.(interface{ CloseIdleConnections() }); ok {
+		c.CloseIdleConnections()
+	}
+	return nil
+}
+
+// fileHashes returns the concatenated SHA-256 hashes of the configured files.
+func (t *tlsRoundTripper) fileHashes() ([]byte, error) {
+	var hashes []byte
+	for _, filename := range []string{t.config.CAFile, t.config.CertFile, t.config.KeyFile} {
+		if filename == "" {
+			continue
+		}
+		b, err := ioutil.ReadFile(filename)
+		if err != nil {
+			return nil, err
+		}
+		h := sha256.Sum256(b)
+		hashes = append(hashes, h[:]...)
+	}
+	return hashes, nil
+}
@@ -0,0 +1,246 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  []byte
+}
+
+func newTestCA(t *testing.T, name string) *testCA {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testCA{
+		cert: cert,
+		key:  key,
+		pem:  pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+	}
+}
+
+// issue returns a PEM encoded certificate and key signed by ca.
+func (ca *testCA) issue(t *testing.T, dnsName string, usage x509.ExtKeyUsage) ([]byte, []byte) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: dnsName},
+		DNSNames:     []string{dnsName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+}
+
+func newTLSServer(t *testing.T, ca *testCA, configure func(*tls.Config)) *httptest.Server {
+	certPEM, keyPEM := ca.issue(t, "momo.local", x509.ExtKeyUsageServerAuth)
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("{}"))
+	}))
+	h.TLS = &tls.Config{Certificates: []tls.Certificate{cert}}
+	if configure != nil {
+		configure(h.TLS)
+	}
+	h.StartTLS()
+	return h
+}
+
+func tryFetch(fetch func() (io.ReadCloser, error)) error {
+	body, err := fetch()
+	if err != nil {
+		return err
+	}
+	return body.Close()
+}
+
+func TestTLSCAFileAndServerName(t *testing.T) {
+	ca := newTestCA(t, "momo CA")
+	h := newTLSServer(t, ca, nil)
+	defer h.Close()
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	writeFile(t, caFile, string(ca.pem))
+
+	fetch := newFetch(t, h.URL, HTTPClientConfig{TLSConfig: TLSConfig{CAFile: caFile}})
+	if err := tryFetch(fetch); err == nil {
+		t.Error("expected hostname verification to fail without server name")
+	} else if reason := fetchErrorReason(err); reason != reasonTLS {
+		t.Errorf("want reason %q, have %q for %v", reasonTLS, reason, err)
+	}
+
+	fetch = newFetch(t, h.URL, HTTPClientConfig{TLSConfig: TLSConfig{CAFile: caFile, ServerName: "momo.local"}})
+	if err := tryFetch(fetch); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestTLSClientCertificate(t *testing.T) {
+	ca := newTestCA(t, "momo CA")
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.cert)
+	h := newTLSServer(t, ca, func(c *tls.Config) {
+		c.ClientAuth = tls.RequireAndVerifyClientCert
+		c.ClientCAs = pool
+	})
+	defer h.Close()
+
+	dir := t.TempDir()
+	caFile := filepath.Join(dir, "ca.pem")
+	certFile := filepath.Join(dir, "client.pem")
+	keyFile := filepath.Join(dir, "client.key")
+	certPEM, keyPEM := ca.issue(t, "exporter", x509.ExtKeyUsageClientAuth)
+	writeFile(t, caFile, string(ca.pem))
+	writeFile(t, certFile, string(certPEM))
+	writeFile(t, keyFile, string(keyPEM))
+
+	tlsConfig := TLSConfig{CAFile: caFile, ServerName: "momo.local"}
+	if err := tryFetch(newFetch(t, h.URL, HTTPClientConfig{TLSConfig: tlsConfig})); err == nil {
+		t.Error("expected handshake to fail without client certificate")
+	} else if reason := fetchErrorReason(err); reason != reasonTLS {
+		t.Errorf("want reason %q, have %q for %v", reasonTLS, reason, err)
+	}
+
+	tlsConfig.CertFile = certFile
+	tlsConfig.KeyFile = keyFile
+	if err := tryFetch(newFetch(t, h.URL, HTTPClientConfig{TLSConfig: tlsConfig})); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestTLSCAFileReload(t *testing.T) {
+	ca := newTestCA(t, "momo CA")
+	h := newTLSServer(t, ca, nil)
+	defer h.Close()
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	writeFile(t, caFile, string(newTestCA(t, "other CA").pem))
+
+	fetch := newFetch(t, h.URL, HTTPClientConfig{TLSConfig: TLSConfig{CAFile: caFile, ServerName: "momo.local"}})
+	if err := tryFetch(fetch); err == nil {
+		t.Error("expected verification to fail with unrelated CA")
+	} else if reason := fetchErrorReason(err); reason != reasonTLS {
+		t.Errorf("want reason %q, have %q for %v", reasonTLS, reason, err)
+	}
+
+	writeFile(t, caFile, string(ca.pem))
+	if err := tryFetch(fetch); err != nil {
+		t.Errorf("expected rotated CA file to be used: %v", err)
+	}
+}
+
+func TestTLSCAFileRemoved(t *testing.T) {
+	ca := newTestCA(t, "momo CA")
+	h := newTLSServer(t, ca, nil)
+	defer h.Close()
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	writeFile(t, caFile, string(ca.pem))
+
+	fetch := newFetch(t, h.URL, HTTPClientConfig{TLSConfig: TLSConfig{CAFile: caFile, ServerName: "momo.local"}})
+	if err := tryFetch(fetch); err != nil {
+		t.Fatal(err)
+	}
+
+	// A half-written CA file during rotation.
+	writeFile(t, caFile, "-----BEGIN CERTIFICATE-----\n")
+	if err := tryFetch(fetch); err == nil {
+		t.Error("expected truncated CA file to fail")
+	} else if reason := fetchErrorReason(err); reason != reasonTLS {
+		t.Errorf("want reason %q, have %q for %v", reasonTLS, reason, err)
+	}
+
+	if err := os.Remove(caFile); err != nil {
+		t.Fatal(err)
+	}
+	if err := tryFetch(fetch); err == nil {
+		t.Error("expected missing CA file to fail")
+	} else if reason := fetchErrorReason(err); reason != reasonTLS {
+		t.Errorf("want reason %q, have %q for %v", reasonTLS, reason, err)
+	}
+}
+
+func TestTLSMinVersion(t *testing.T) {
+	h := httptest.NewUnstartedServer(http.NotFoundHandler())
+	h.TLS = &tls.Config{MaxVersion: tls.VersionTLS12}
+	h.StartTLS()
+	defer h.Close()
+
+	fetch := newFetch(t, h.URL, HTTPClientConfig{TLSConfig: TLSConfig{InsecureSkipVerify: true, MinVersion: tls.VersionTLS13}})
+	if err := tryFetch(fetch); err == nil {
+		t.Error("expected handshake to fail below the minimum TLS version")
+	} else if reason := fetchErrorReason(err); reason != reasonTLS {
+		t.Errorf("want reason %q, have %q for %v", reasonTLS, reason, err)
+	}
+}
+
+func TestTLSConfigValidate(t *testing.T) {
+	for name, c := range map[string]TLSConfig{
+		"cert without key": {CertFile: "client.pem"},
+		"key without cert": {KeyFile: "client.key"},
+	} {
+		if err := c.Validate(); err == nil {
+			t.Errorf("%s: expected validation error", name)
+		}
+	}
+}
+
+func TestParseTLSVersion(t *testing.T) {
+	if v, err := ParseTLSVersion("TLS12"); err != nil || v != tls.VersionTLS12 {
+		t.Errorf("want TLS12, have %v %v", v, err)
+	}
+	if _, err := ParseTLSVersion("SSL3"); err == nil {
+		t.Error("expected error for unknown version")
+	}
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/koron/go-dproxy"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// DTLSVersion is a DTLS protocol version as reported in the tlsVersion field
+// of transport stats. DTLS version numbers decrease as versions get newer.
+type DTLSVersion uint16
+
+// DTLSVersions maps the names accepted in configuration to DTLS versions.
+var DTLSVersions = map[string]DTLSVersion{
+	"DTLS10": 0xfeff,
+	"DTLS12": 0xfefd,
+	"DTLS13": 0xfefc,
+}
+
+// UnmarshalYAML implements yaml.Unmarshaler.
+func (v *DTLSVersion) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var s string
+	if err := unmarshal(&s); err != nil {
+		return err
+	}
+	dv, ok := DTLSVersions[s]
+	if !ok {
+		return fmt.Errorf("unknown DTLS version %q", s)
+	}
+	*v = dv
+	return nil
+}
+
+// atLeast reports whether v is the same version as min or newer.
+func (v DTLSVersion) atLeast(min DTLSVersion) bool {
+	return v <= min
+}
+
+// TransportSecurityPolicy lists the transport security parameters a session
+// may negotiate. Empty lists allow any value.
+type TransportSecurityPolicy struct {
+	SRTPCiphers    []string    `yaml:"srtp_ciphers,omitempty"`
+	DTLSCiphers    []string    `yaml:"dtls_ciphers,omitempty"`
+	MinDTLSVersion DTLSVersion `yaml:"min_dtls_version,omitempty"`
+}
+
+// violated reports whether the negotiated parameters of a transport fall
+// outside the policy. Parameters that are not negotiated yet are ignored.
+func (p *TransportSecurityPolicy) violated(srtpCipher, dtlsCipher, tlsVersion string) bool {
+	if srtpCipher != "" && !allows(p.SRTPCiphers, srtpCipher) {
+		return true
+	}
+	if dtlsCipher != "" && !allows(p.DTLSCiphers, dtlsCipher) {
+		return true
+	}
+	if tlsVersion != "" && p.MinDTLSVersion != 0 {
+		v, err := strconv.ParseUint(tlsVersion, 16, 16)
+		if err != nil || !DTLSVersion(v).atLeast(p.MinDTLSVersion) {
+			return true
+		}
+	}
+	return false
+}
+
+// allows reports whether allowed is empty or contains s.
+func allows(allowed []string, s string) bool {
+	if len(allowed) == 0 {
+		return true
+	}
+	for _, a := range allowed {
+		if a == s {
+			return true
+		}
+	}
+	return false
+}
+
+var (
+	transportSecurityLabelNames = []string{"id", "tlsVersion", "dtlsCipher", "srtpCipher", "dtlsRole", "iceRole"}
+	transportSecurityInfo       = newDesc("transport", "security_info",
+		"Security parameters negotiated on this transport.", transportSecurityLabelNames)
+	transportSecurityPolicyViolation = newDesc("transport", "security_policy_violation",
+		"Whether this transport negotiated security parameters outside the configured policy.", []string{"id"})
+)
+
+func (e *Exporter) exportTransportSecurityMetrics(m dproxy.Proxy, ch chan<- prometheus.Metric) {
+	values := make([]string, len(transportSecurityLabelNames))
+	for i, name := range transportSecurityLabelNames {
+		values[i], _ = m.M(name).String()
+	}
+	e.sendConst(ch, transportSecurityInfo, prometheus.GaugeValue, 1, values...)
+
+	if e.securityPolicy == nil {
+		return
+	}
+	id, _ := m.M("id").String()
+	tlsVersion, _ := m.M("tlsVersion").String()
+	dtlsCipher, _ := m.M("dtlsCipher").String()
+	srtpCipher, _ := m.M("srtpCipher").String()
+	var violation float64
+	if e.securityPolicy.violated(srtpCipher, dtlsCipher, tlsVersion) {
+		violation = 1
+	}
+	e.sendConst(ch, transportSecurityPolicyViolation, prometheus.GaugeValue, violation, id)
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/prometheus/common/model"
+)
+
+func TestTransportSecurityPolicyViolated(t *testing.T) {
+	policy := &TransportSecurityPolicy{
+		SRTPCiphers:    []string{"AEAD_AES_128_GCM", "AEAD_AES_256_GCM"},
+		MinDTLSVersion: DTLSVersions["DTLS12"],
+	}
+	for _, tc := range []struct {
+		srtp, dtls, version string
+		want                bool
+	}{
+		{"AEAD_AES_128_GCM", "TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256", "FEFD", false},
+		{"AEAD_AES_256_GCM", "TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384", "FEFC", false},
+		{"AES_CM_128_HMAC_SHA1_80", "TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256", "FEFD", true},
+		{"AEAD_AES_128_GCM", "TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA", "FEFF", true},
+		{"AEAD_AES_128_GCM", "", "not-hex", true},
+		// Not negotiated yet.
+		{"", "", "", false},
+	} {
+		if got := policy.violated(tc.srtp, tc.dtls, tc.version); got != tc.want {
+			t.Errorf("violated(%q, %q, %q) = %v; want %v", tc.srtp, tc.dtls, tc.version, got, tc.want)
+		}
+	}
+}
+
+func TestTransportSecurityPolicy(t *testing.T) {
+	resp := `{
+		"version": "WebRTC Native Client Momo 2020.11 (db9d97e)",
+		"libwebrtc": "Shiguredo-Build M88.4324@{#2} (88.4324.2.0 54bd8488)",
+		"environment": "[aarch64] Ubuntu 18.04.5 LTS (nvidia-l4t-core 32.4.4-20201016123640)",
+		"stats": [
+			{
+				"dtlsCipher": "TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256",
+				"dtlsRole": "client",
+				"dtlsState": "connected",
+				"iceRole": "controlling",
+				"id": "RTCTransport_0_1",
+				"srtpCipher": "AEAD_AES_128_GCM",
+				"timestamp": 1608309189926189,
+				"tlsVersion": "FEFD",
+				"type": "transport"
+			},
+			{
+				"dtlsCipher": "TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256",
+				"dtlsRole": "client",
+				"dtlsState": "connected",
+				"iceRole": "controlling",
+				"id": "RTCTransport_1_1",
+				"srtpCipher": "AES_CM_128_HMAC_SHA1_80",
+				"timestamp": 1608309189926189,
+				"tlsVersion": "FEFD",
+				"type": "transport"
+			}
+		]
+	}`
+	h := newMomo([]byte(resp))
+	defer h.Close()
+	e, err := NewExporter(TargetConfig{
+		URI:     h.URL,
+		Timeout: model.Duration(5 * time.Second),
+		ExportConfig: ExportConfig{
+			StatsTypes: []string{"transport"},
+			TransportSecurityPolicy: &TransportSecurityPolicy{
+				SRTPCiphers:    []string{"AEAD_AES_128_GCM", "AEAD_AES_256_GCM"},
+				MinDTLSVersion: DTLSVersions["DTLS12"],
+			},
+		},
+	}, log.NewNopLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	e.now = func() time.Time { return testTime }
+	expectMetrics(t, e, "transport_security_policy")
+}
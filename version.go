@@ -0,0 +1,98 @@
+package main
+
+import (
+	"regexp"
+	"strconv"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	// e.g. "WebRTC Native Client Momo 2020.11 (db9d97e)"
+	momoVersionRE = regexp.MustCompile(`Momo (\d+)\.(\d+)(?:\.(\d+))?(?: \(([0-9a-f]+)\))?`)
+	// e.g. "Shiguredo-Build M88.4324@{#2} (88.4324.2.0 54bd8488)"
+	libwebrtcVersionRE = regexp.MustCompile(`M(\d+)\.(\d+)@\{#(\d+)\}(?: \([0-9.]+ ([0-9a-f]+)\))?`)
+)
+
+var (
+	buildInfo = newDesc("build", "info",
+		"WebRTC Native Client Momo build info parsed from the version strings.",
+		[]string{"release", "commit", "libwebrtc_milestone", "libwebrtc_branch", "libwebrtc_build", "libwebrtc_hash"})
+	buildRelease = newDesc("build", "release",
+		"WebRTC Native Client Momo release as a number, e.g. 2020.11 or 2021.0101 for 2021.1.1.", nil)
+	libwebrtcMilestone = newDesc("libwebrtc", "milestone",
+		"Milestone of the libwebrtc WebRTC Native Client Momo is built on.", nil)
+)
+
+// momoVersion is a parsed Momo version string.
+type momoVersion struct {
+	Release string
+	Commit  string
+
+	year, minor, patch int
+}
+
+// parseMomoVersion parses the version reported by Momo. ok is false if s
+// does not contain a Momo release.
+func parseMomoVersion(s string) (v momoVersion, ok bool) {
+	m := momoVersionRE.FindStringSubmatch(s)
+	if m == nil {
+		return v, false
+	}
+	v.year, _ = strconv.Atoi(m[1])
+	v.minor, _ = strconv.Atoi(m[2])
+	v.Release = m[1] + "." + m[2]
+	if m[3] != "" {
+		v.patch, _ = strconv.Atoi(m[3])
+		v.Release += "." + m[3]
+	}
+	v.Commit = m[4]
+	return v, true
+}
+
+// Number returns the release as year + minor/100 + patch/10000 so that
+// releases compare in order.
+func (v momoVersion) Number() float64 {
+	return float64(v.year) + float64(v.minor)/100 + float64(v.patch)/10000
+}
+
+// libwebrtcVersion is a parsed libwebrtc build string.
+type libwebrtcVersion struct {
+	Milestone int
+	Branch    string
+	Build     string
+	Hash      string
+}
+
+// parseLibwebrtcVersion parses the libwebrtc build reported by Momo. ok is
+// false if s does not contain a milestone.
+func parseLibwebrtcVersion(s string) (v libwebrtcVersion, ok bool) {
+	m := libwebrtcVersionRE.FindStringSubmatch(s)
+	if m == nil {
+		return v, false
+	}
+	v.Milestone, _ = strconv.Atoi(m[1])
+	v.Branch = m[2]
+	v.Build = m[3]
+	v.Hash = m[4]
+	return v, true
+}
+
+func (e *Exporter) exportBuildMetrics(metrics MomoMetrics, ch chan<- prometheus.Metric) {
+	momo, momoOK := parseMomoVersion(metrics.Version)
+	webrtc, webrtcOK := parseLibwebrtcVersion(metrics.Libwebrtc)
+
+	var milestone string
+	if webrtcOK {
+		milestone = strconv.Itoa(webrtc.Milestone)
+	}
+	e.sendConst(ch, buildInfo, prometheus.GaugeValue, 1,
+		momo.Release, momo.Commit, milestone, webrtc.Branch, webrtc.Build, webrtc.Hash)
+
+	if momoOK {
+		e.sendConst(ch, buildRelease, prometheus.GaugeValue, momo.Number())
+	}
+	if webrtcOK {
+		e.sendConst(ch, libwebrtcMilestone, prometheus.GaugeValue, float64(webrtc.Milestone))
+	}
+}
@@ -0,0 +1,43 @@
+package main
+
+import "testing"
+
+func TestParseMomoVersion(t *testing.T) {
+	for _, tc := range []struct {
+		in     string
+		want   momoVersion
+		number float64
+		ok     bool
+	}{
+		{"WebRTC Native Client Momo 2020.11 (db9d97e)", momoVersion{Release: "2020.11", Commit: "db9d97e", year: 2020, minor: 11}, 2020.11, true},
+		{"WebRTC Native Client Momo 2021.1.1 (0a1b2c3)", momoVersion{Release: "2021.1.1", Commit: "0a1b2c3", year: 2021, minor: 1, patch: 1}, 2021.0101, true},
+		{"WebRTC Native Client Momo 2020.8", momoVersion{Release: "2020.8", year: 2020, minor: 8}, 2020.08, true},
+		{"WebRTC Native Client Momo 20XX.Y (test)", momoVersion{}, 0, false},
+		{"", momoVersion{}, 0, false},
+	} {
+		got, ok := parseMomoVersion(tc.in)
+		if ok != tc.ok || got != tc.want {
+			t.Errorf("parseMomoVersion(%q) = %+v, %v; want %+v, %v", tc.in, got, ok, tc.want, tc.ok)
+		}
+		if n := got.Number(); n != tc.number {
+			t.Errorf("parseMomoVersion(%q).Number() = %v; want %v", tc.in, n, tc.number)
+		}
+	}
+}
+
+func TestParseLibwebrtcVersion(t *testing.T) {
+	for _, tc := range []struct {
+		in   string
+		want libwebrtcVersion
+		ok   bool
+	}{
+		{"Shiguredo-Build M88.4324@{#2} (88.4324.2.0 54bd8488)", libwebrtcVersion{Milestone: 88, Branch: "4324", Build: "2", Hash: "54bd8488"}, true},
+		{"Shiguredo-Build M102.5005@{#12}", libwebrtcVersion{Milestone: 102, Branch: "5005", Build: "12"}, true},
+		{"Test-Build MXX.YYYY@{#Z} (XX.YYYY.Z test)", libwebrtcVersion{}, false},
+	} {
+		got, ok := parseLibwebrtcVersion(tc.in)
+		if ok != tc.ok || got != tc.want {
+			t.Errorf("parseLibwebrtcVersion(%q) = %+v, %v; want %+v, %v", tc.in, got, ok, tc.want, tc.ok)
+		}
+	}
+}